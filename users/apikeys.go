@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// ApiKeyPrefix marks our keys so they are easy to spot in logs and secret scanners
+	ApiKeyPrefix = "usk"
+	ApiKeyHeader = "X-API-Key"
+)
+
+var ErrInvalidApiKey = errors.New("invalid api key")
+
+// ApiKeyPrincipal is the identity an authenticated api key acts as
+type ApiKeyPrincipal struct {
+	KeyID          int32
+	OrganizationID int32
+	UserID         int64
+	Role           int64
+	ServiceAccount string
+	Permissions    []string
+}
+
+// GenerateApiKey returns a new key in the form usk_<prefix>_<secret> together
+// with its lookup prefix and the hash that gets stored
+func GenerateApiKey() (key, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+	if _, err = rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = fmt.Sprintf("%s_%s_%s", ApiKeyPrefix, prefix, hex.EncodeToString(secretBytes))
+	return key, prefix, HashApiKey(key), nil
+}
+
+// HashApiKey uses a plain sha256, keys are random enough not to need a slow hash
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseApiKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != ApiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// AuthenticateApiKey resolves a raw key to the principal it belongs to. Personal
+// keys never grant more than the owner's current role and groups in the key's
+// organization allow, and are refused while the owner is deactivated or
+// waiting for approval.
+func AuthenticateApiKey(ctx context.Context, repo *repository.Queries, key string) (*ApiKeyPrincipal, error) {
+	prefix, ok := parseApiKeyPrefix(key)
+	if !ok {
+		return nil, ErrInvalidApiKey
+	}
+
+	apiKey, err := repo.GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, ErrInvalidApiKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(HashApiKey(key))) != 1 {
+		return nil, ErrInvalidApiKey
+	}
+
+	principal := &ApiKeyPrincipal{
+		KeyID:          apiKey.ID,
+		OrganizationID: apiKey.OrganizationID,
+		ServiceAccount: apiKey.ServiceAccount.String,
+		Permissions:    apiKey.Permissions,
+	}
+
+	if apiKey.UserID.Valid {
+		user, err := tenantUser(ctx, repo, int64(apiKey.UserID.Int32), apiKey.OrganizationID)
+		if err != nil {
+			return nil, ErrInvalidApiKey
+		}
+
+		// keys stop working with their owner's account
+		if canSignIn(user) != nil {
+			return nil, ErrInvalidApiKey
+		}
+
+		permissions, err := userPermissions(ctx, repo, user, apiKey.OrganizationID)
+		if err != nil {
+			return nil, ErrInvalidApiKey
+		}
+
+		principal.UserID = int64(user.ID)
+		principal.Role = user.Role
+		principal.Permissions = intersect(apiKey.Permissions, permissions)
+	}
+
+	if err := repo.TouchApiKey(ctx, apiKey.ID); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+func intersect(a, b []string) []string {
+	out := make([]string, 0)
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func newApiKeyDTO(key repository.ApiKey) ApiKeyDTO {
+	return ApiKeyDTO{
+		ID:             key.ID,
+		Name:           key.Name,
+		Prefix:         key.Prefix,
+		UserID:         key.UserID,
+		ServiceAccount: key.ServiceAccount,
+		Permissions:    key.Permissions,
+		ExpiresAt:      key.ExpiresAt,
+		LastUsedAt:     key.LastUsedAt,
+		CreatedAt:      key.CreatedAt,
+	}
+}
+
+// api keys handlers
+
+func (h *AuthHandler) ListApiKeys(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	permissions, _ := c.Get("permissions").([]string)
+
+	var keys []repository.ApiKey
+	var err error
+	if c.QueryParam("all") == "true" {
+		if !slices.Contains(permissions, ApiKeysManagePermission) {
+			return NewResponse(c, "forbidden", nil, "invalid permissions", http.StatusForbidden)
+		}
+		keys, err = h.Repo.ListApiKeys(h.Ctx, tenantID(c))
+	} else {
+		keys, err = h.Repo.ListUserApiKeys(h.Ctx, repository.ListUserApiKeysParams{
+			OrganizationID: tenantID(c),
+			UserID:         pgtype.Int4{Int32: int32(userID), Valid: true},
+		})
+	}
+	if err != nil {
+		return DBError(err, "api key")
+	}
+
+	keysDTO := make([]ApiKeyDTO, 0)
+	for _, key := range keys {
+		keysDTO = append(keysDTO, newApiKeyDTO(key))
+	}
+
+	return NewResponse(c, "success", keysDTO, "", http.StatusOK)
+}
+
+func (h *AuthHandler) CreateApiKey(c echo.Context) error {
+	data := new(CreateApiKeyDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	permissions, _ := c.Get("permissions").([]string)
+
+	// a key can only carry permissions its creator already has
+	for _, p := range data.Permissions {
+		if !slices.Contains(permissions, p) {
+			return NewResponse(c, "forbidden", nil, fmt.Sprintf("permission %s is not granted to the caller", p), http.StatusForbidden)
+		}
+	}
+
+	if data.ExpiresAt != nil && data.ExpiresAt.Before(time.Now()) {
+		return NewResponse(c, "failed", nil, "expires_at must be in the future", http.StatusBadRequest)
+	}
+
+	params := repository.CreateApiKeyParams{
+		OrganizationID: tenantID(c),
+		Name:           data.Name,
+		Permissions:    data.Permissions,
+	}
+
+	if data.ServiceAccount != "" {
+		if !slices.Contains(permissions, ApiKeysManagePermission) {
+			return NewResponse(c, "forbidden", nil, "invalid permissions", http.StatusForbidden)
+		}
+		params.ServiceAccount = pgtype.Text{String: data.ServiceAccount, Valid: true}
+	} else {
+		if userID == 0 {
+			return NewResponse(c, "failed", nil, "service_account is required", http.StatusBadRequest)
+		}
+		params.UserID = pgtype.Int4{Int32: int32(userID), Valid: true}
+	}
+
+	if data.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamp{Time: *data.ExpiresAt, Valid: true}
+	}
+
+	key, prefix, hash, err := GenerateApiKey()
+	if err != nil {
+		return InternalError(err)
+	}
+	params.Prefix = prefix
+	params.KeyHash = hash
+
+	apiKey, err := h.Repo.CreateApiKey(h.Ctx, params)
+	if err != nil {
+		return DBError(err, "api key")
+	}
+
+	// the plain key is only ever returned here
+	responseData := CreatedApiKeyDTO{
+		Key:    key,
+		ApiKey: newApiKeyDTO(apiKey),
+	}
+	return NewResponse(c, "success", responseData, "", http.StatusAccepted)
+}
+
+func (h *AuthHandler) RevokeApiKey(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	apiKey, err := h.Repo.GetApiKey(h.Ctx, repository.GetApiKeyParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "api key")
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	permissions, _ := c.Get("permissions").([]string)
+	owner := apiKey.UserID.Valid && int64(apiKey.UserID.Int32) == userID
+	if !owner && !slices.Contains(permissions, ApiKeysManagePermission) {
+		return NewResponse(c, "forbidden", nil, "invalid permissions", http.StatusForbidden)
+	}
+
+	err = h.Repo.RevokeApiKey(h.Ctx, repository.RevokeApiKeyParams{ID: apiKey.ID, OrganizationID: apiKey.OrganizationID})
+	if err != nil {
+		return DBError(err, "api key")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
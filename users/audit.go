@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// audit actions
+const (
+	AuditUserExport  = "users.export"
+	AuditUserErase   = "users.erase"
+	AuditUserApprove = "users.approve"
+	AuditUserReject  = "users.reject"
+)
+
+// recordAudit stores who performed action on userID. metadata must never
+// contain PII, entries are kept after the user is erased.
+func recordAudit(ctx context.Context, repo *repository.Queries, c echo.Context, action string, userID int32, metadata map[string]any) error {
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	actorID, _ := c.Get("userID").(int64)
+	return repo.CreateAuditEntry(ctx, repository.CreateAuditEntryParams{
+		ActorID:  pgtype.Int4{Int32: int32(actorID), Valid: actorID != 0},
+		Action:   action,
+		UserID:   userID,
+		Metadata: data,
+	})
+}
+
+func newAuditEntriesDTO(entries []repository.AuditLog) []AuditEntryDTO {
+	entriesDTO := make([]AuditEntryDTO, 0)
+	for _, entry := range entries {
+		entriesDTO = append(entriesDTO, AuditEntryDTO{
+			ID:        entry.ID,
+			ActorID:   entry.ActorID,
+			Action:    entry.Action,
+			UserID:    entry.UserID,
+			Metadata:  json.RawMessage(entry.Metadata),
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return entriesDTO
+}
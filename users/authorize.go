@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// reasons of an authorization decision, part of the api like error codes
+const (
+	ReasonGranted           = "granted"
+	ReasonPublicRoute       = "public_route"
+	ReasonAuthenticated     = "authenticated"
+	ReasonUnauthenticated   = "unauthenticated"
+	ReasonPermissionMissing = "permission_missing"
+	ReasonUserInactive      = "user_inactive"
+	ReasonApprovalPending   = "approval_pending"
+	ReasonSubjectNotFound   = "subject_not_found"
+	ReasonUnknownRoute      = "unknown_route"
+	ReasonTenantMismatch    = "tenant_mismatch"
+)
+
+// headers a gateway's forward-auth hook sends along with the original
+// request's credentials, and the ones we answer an allowed request with
+const (
+	ForwardedMethodHeader  = "X-Forwarded-Method"
+	ForwardedUriHeader     = "X-Forwarded-Uri"
+	AuthUserIDHeader       = "X-Auth-User-Id"
+	AuthRoleHeader         = "X-Auth-Role"
+	AuthOrganizationHeader = "X-Auth-Organization-Id"
+)
+
+// authzSubject is who a decision is about. Reason is set when the subject is
+// denied everything, e.g. an invalid token or an inactive user.
+type authzSubject struct {
+	UserID         int64
+	OrganizationID int32
+	Role           int64
+	Permissions    []string
+	Reason         string
+}
+
+// userRoles are the roles granting the user permissions in an organization,
+// their own role followed by the roles granted to their groups. user comes
+// from tenantUser so its role is the one in organizationID. A deleted role
+// grants nothing.
+func userRoles(ctx context.Context, repo *repository.Queries, user repository.User, organizationID int32) ([]repository.Role, error) {
+	roles := make([]repository.Role, 0)
+	role, err := repo.GetRole(ctx, repository.GetRoleParams{ID: int32(user.Role), OrganizationID: organizationID})
+	switch {
+	case err == nil:
+		roles = append(roles, role)
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, DBError(err, "role")
+	}
+
+	groupRoles, err := repo.ListUserGroupRoles(ctx, repository.ListUserGroupRolesParams{
+		OrganizationID: organizationID,
+		UserID:         user.ID,
+	})
+	if err != nil {
+		return nil, DBError(err, "role")
+	}
+	for _, groupRole := range groupRoles {
+		if groupRole.ID != role.ID {
+			roles = append(roles, groupRole)
+		}
+	}
+	return roles, nil
+}
+
+// userPermissions is the union of the permissions of the user's roles
+func userPermissions(ctx context.Context, repo *repository.Queries, user repository.User, organizationID int32) ([]string, error) {
+	roles, err := userRoles(ctx, repo, user, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0)
+	for _, role := range roles {
+		for _, permission := range tenantPermissions(organizationID, role.Permissions) {
+			if !HasPermission(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// subjectPermissions uses the permissions the user's roles grant now in the
+// organization, not the ones frozen into a token. Inactive and unapproved
+// users are granted nothing, shared by POST /authorize and the grpc
+// CheckPermission.
+func subjectPermissions(ctx context.Context, repo *repository.Queries, userID int64, organizationID int32) (*authzSubject, error) {
+	user, err := tenantUser(ctx, repo, userID, organizationID)
+	if err != nil {
+		return nil, DBError(err, "user")
+	}
+
+	subject := &authzSubject{UserID: int64(user.ID), OrganizationID: organizationID, Role: user.Role}
+	if !user.IsActive.Bool {
+		subject.Reason = ReasonUserInactive
+		return subject, nil
+	}
+	if user.ApprovalPending.Bool {
+		subject.Reason = ReasonApprovalPending
+		return subject, nil
+	}
+
+	subject.Permissions, err = userPermissions(ctx, repo, user, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// requestCredentials reads an api key or a bearer token from the request
+func requestCredentials(c echo.Context) (apiKey, token string) {
+	if key := c.Request().Header.Get(ApiKeyHeader); key != "" {
+		return key, ""
+	}
+	token, _ = strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	return "", token
+}
+
+// credentialSubject authenticates an api key or an access token like the
+// Authenticate middleware does, credentials of another organization than
+// the one the request names are denied everything
+func (h *AuthHandler) credentialSubject(c echo.Context, apiKey, token string) *authzSubject {
+	ctx := c.Request().Context()
+	subject := &authzSubject{}
+	if apiKey != "" {
+		principal, err := AuthenticateApiKey(ctx, h.Repo, apiKey)
+		if err != nil {
+			return &authzSubject{Reason: ReasonUnauthenticated}
+		}
+		subject = &authzSubject{UserID: principal.UserID, OrganizationID: principal.OrganizationID, Role: principal.Role, Permissions: principal.Permissions}
+	} else {
+		if token == "" {
+			return &authzSubject{Reason: ReasonUnauthenticated}
+		}
+		claims, err := AuthenticateToken(ctx, h.Cfg, h.Repo, token)
+		if err != nil {
+			return &authzSubject{Reason: ReasonUnauthenticated}
+		}
+		subject = &authzSubject{UserID: claims.UserID, OrganizationID: claims.OrganizationID, Role: claims.Role, Permissions: claims.Permissions}
+	}
+
+	if err := useCredentialTenant(c, h.Repo, subject.OrganizationID); err != nil {
+		return &authzSubject{UserID: subject.UserID, OrganizationID: subject.OrganizationID, Reason: ReasonTenantMismatch}
+	}
+	return subject
+}
+
+// authorizeSubject resolves who a decision is about. A subject id asks about
+// a user by their current roles, only callers allowed to introspect may do
+// that. Otherwise the token of the body or the request's own credentials are
+// checked, which is what a forward-auth hook sends.
+func (h *AuthHandler) authorizeSubject(c echo.Context, token string, subjectID int64) (*authzSubject, error) {
+	apiKey, bearer := requestCredentials(c)
+	if subjectID == 0 {
+		if token != "" {
+			return h.credentialSubject(c, "", token), nil
+		}
+		return h.credentialSubject(c, apiKey, bearer), nil
+	}
+
+	caller := h.credentialSubject(c, apiKey, bearer)
+	if caller.Reason == ReasonTenantMismatch {
+		return nil, ForbiddenError(ErrTenantMismatch.Error())
+	}
+	if caller.Reason != "" {
+		return nil, &DomainError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: "invalid or missing credentials"}
+	}
+	if !HasPermission(caller.Permissions, AuthIntrospectPermission) {
+		return nil, ForbiddenError("invalid permissions")
+	}
+
+	// subjects are looked up in the caller's organization
+	subject, err := subjectPermissions(c.Request().Context(), h.Repo, subjectID, caller.OrganizationID)
+	if errors.Is(err, ErrNotFound) {
+		return &authzSubject{UserID: subjectID, OrganizationID: caller.OrganizationID, Reason: ReasonSubjectNotFound}, nil
+	}
+	return subject, err
+}
+
+// decide answers one check, a route of this service stands for the
+// permission that guards it
+func (h *AuthHandler) decide(subject *authzSubject, check AuthorizeCheckDTO) AuthorizeDecisionDTO {
+	decision := AuthorizeDecisionDTO{
+		Permission: check.Permission,
+		Method:     check.Method,
+		Path:       check.Path,
+		UserID:     subject.UserID,
+	}
+
+	public := false
+	if check.Permission == "" {
+		route, ok := h.Routes.Match(check.Method, check.Path)
+		if !ok {
+			decision.Reason = ReasonUnknownRoute
+			return decision
+		}
+		decision.Permission = route.Permission
+		public = route.Public
+	}
+
+	switch {
+	case public:
+		decision.Allowed, decision.Reason = true, ReasonPublicRoute
+	case subject.Reason != "":
+		decision.Reason = subject.Reason
+	case decision.Permission == "":
+		decision.Allowed, decision.Reason = true, ReasonAuthenticated
+	case HasPermission(subject.Permissions, decision.Permission):
+		decision.Allowed, decision.Reason = true, ReasonGranted
+	default:
+		decision.Reason = ReasonPermissionMissing
+	}
+	return decision
+}
+
+// validateAuthorizeCheck requires a permission or a path, which the
+// validator cannot express with json field names
+func validateAuthorizeCheck(check AuthorizeCheckDTO, field string) ValidationErrors {
+	if check.Permission != "" || check.Path != "" {
+		return nil
+	}
+	return ValidationErrors{{
+		Field:   field,
+		Rule:    "required_without",
+		Message: "is required when path is not set",
+		Params:  map[string]any{"required_without": "path"},
+	}}
+}
+
+// Authorize answers whether a token, the request's own credentials or a
+// subject may do something. Gateways can use it as a forward-auth hook: it
+// answers 200 when allowed, 401 without valid credentials and 403 otherwise,
+// and falls back on the permission query parameter and the forwarded method
+// and uri when the body names no check.
+func (h *AuthHandler) Authorize(c echo.Context) error {
+	data := new(AuthorizeDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	check := AuthorizeCheckDTO{Permission: data.Permission, Method: data.Method, Path: data.Path}
+	if check.Permission == "" {
+		check.Permission = c.QueryParam("permission")
+	}
+	if check.Permission == "" && check.Path == "" {
+		check.Method = c.Request().Header.Get(ForwardedMethodHeader)
+		check.Path = c.Request().Header.Get(ForwardedUriHeader)
+	}
+	if fields := validateAuthorizeCheck(check, "permission"); fields != nil {
+		return ValidationError(fields)
+	}
+
+	subject, err := h.authorizeSubject(c, data.Token, data.Subject)
+	if err != nil {
+		return err
+	}
+
+	decision := h.decide(subject, check)
+	switch {
+	case decision.Allowed:
+		if decision.UserID != 0 {
+			c.Response().Header().Set(AuthUserIDHeader, strconv.FormatInt(decision.UserID, 10))
+			c.Response().Header().Set(AuthRoleHeader, strconv.FormatInt(subject.Role, 10))
+			c.Response().Header().Set(AuthOrganizationHeader, strconv.Itoa(int(subject.OrganizationID)))
+		}
+		return NewResponse(c, "allowed", decision, "", http.StatusOK)
+	case decision.Reason == ReasonUnauthenticated:
+		return NewResponse(c, "denied", decision, decision.Reason, http.StatusUnauthorized)
+	}
+	return NewResponse(c, "denied", decision, decision.Reason, http.StatusForbidden)
+}
+
+// AuthorizeBatch answers several checks for one subject at once, denials
+// are part of the answer so it is 200 whatever the decisions
+func (h *AuthHandler) AuthorizeBatch(c echo.Context) error {
+	data := new(AuthorizeBatchDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	fields := make(ValidationErrors, 0)
+	for i, check := range data.Checks {
+		fields = append(fields, validateAuthorizeCheck(check, fmt.Sprintf("checks[%d].permission", i))...)
+	}
+	if len(fields) > 0 {
+		return ValidationError(fields)
+	}
+
+	subject, err := h.authorizeSubject(c, data.Token, data.Subject)
+	if err != nil {
+		return err
+	}
+
+	result := AuthorizeBatchDecisionDTO{
+		UserID:    subject.UserID,
+		Allowed:   true,
+		Decisions: make([]AuthorizeDecisionDTO, 0, len(data.Checks)),
+	}
+	for _, check := range data.Checks {
+		decision := h.decide(subject, check)
+		result.Allowed = result.Allowed && decision.Allowed
+		result.Decisions = append(result.Decisions, decision)
+	}
+
+	return NewResponse(c, "success", result, "", http.StatusOK)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestRouteRegistryMatch(t *testing.T) {
+	routes := NewRouteRegistry("/v1/auth", []Route{
+		{Method: http.MethodGet, Path: "/users/:id", Permission: UsersReadPermission},
+		{Method: http.MethodGet, Path: "/users/pending-approval", Permission: UsersApprovePermission},
+		{Method: http.MethodGet, Path: "/health", Public: true},
+	})
+
+	tests := []struct {
+		method, path string
+		permission   string
+		found        bool
+	}{
+		{http.MethodGet, "/v1/auth/users/42", UsersReadPermission, true},
+		{http.MethodGet, "/users/42?expand=role", UsersReadPermission, true},
+		{http.MethodGet, "/v1/auth/users/pending-approval", UsersApprovePermission, true},
+		{http.MethodGet, "/v1/auth/health", "", true},
+		{http.MethodPost, "/v1/auth/users/42", "", false},
+		{http.MethodGet, "/v1/auth/users/", "", false},
+		{http.MethodGet, "/v1/auth/users/42/sessions", "", false},
+	}
+	for _, tt := range tests {
+		route, found := routes.Match(tt.method, tt.path)
+		if found != tt.found || route.Permission != tt.permission {
+			t.Errorf("Match(%s %s) = %q, %v, want %q, %v", tt.method, tt.path, route.Permission, found, tt.permission, tt.found)
+		}
+	}
+}
+
+func TestAuthorizeWithoutDatabase(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		headers map[string]string
+		status  int
+		reason  string
+	}{
+		{
+			name:    "forwarded public route",
+			path:    "/v1/auth/authorize",
+			headers: map[string]string{ForwardedMethodHeader: http.MethodPost, ForwardedUriHeader: "/v1/auth/login"},
+			status:  http.StatusOK,
+			reason:  ReasonPublicRoute,
+		},
+		{
+			name:    "forwarded guarded route without credentials",
+			path:    "/v1/auth/authorize",
+			headers: map[string]string{ForwardedMethodHeader: http.MethodGet, ForwardedUriHeader: "/v1/auth/users/1"},
+			status:  http.StatusUnauthorized,
+			reason:  ReasonUnauthenticated,
+		},
+		{
+			name:    "permission with an invalid token",
+			path:    "/v1/auth/authorize?permission=loyalty:redeem",
+			headers: map[string]string{"Authorization": "Bearer not-a-token"},
+			status:  http.StatusUnauthorized,
+			reason:  ReasonUnauthenticated,
+		},
+		{
+			name:   "unknown route",
+			path:   "/v1/auth/authorize",
+			body:   `{"method":"GET","path":"/v1/auth/nothing-here"}`,
+			status: http.StatusForbidden,
+			reason: ReasonUnknownRoute,
+		},
+		{
+			name:   "subject without introspecting caller",
+			path:   "/v1/auth/authorize",
+			body:   `{"subject":1,"permission":"users:read"}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "nothing to check",
+			path:   "/v1/auth/authorize",
+			body:   `{}`,
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			s.Echo.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.reason == "" {
+				return
+			}
+
+			var res struct {
+				Data AuthorizeDecisionDTO `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Data.Reason != tt.reason {
+				t.Errorf("reason %q, want %q", res.Data.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestCanSignIn(t *testing.T) {
+	tests := []struct {
+		name            string
+		active, pending bool
+		code            string
+	}{
+		{"active", true, false, ""},
+		{"deactivated", false, false, CodeAccountInactive},
+		{"deactivated while pending", false, true, CodeAccountInactive},
+		{"pending approval", true, true, CodeApprovalPending},
+	}
+	for _, tt := range tests {
+		user := repository.User{
+			IsActive:        pgtype.Bool{Bool: tt.active, Valid: true},
+			ApprovalPending: pgtype.Bool{Bool: tt.pending, Valid: true},
+		}
+		err := canSignIn(user)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s: canSignIn() = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		domainErr, ok := err.(*DomainError)
+		if !ok || domainErr.Code != tt.code || domainErr.Status != http.StatusForbidden {
+			t.Errorf("%s: canSignIn() = %v, want %s", tt.name, err, tt.code)
+		}
+	}
+}
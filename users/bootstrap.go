@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AllPermissions grants a manifest role every permission in the manifest
+const AllPermissions = "*"
+
+//go:embed bootstrap.json
+var defaultManifest []byte
+
+// Manifest declares the roles that must exist for the routes to be usable,
+// plus optional extra permissions not required by any route. Bootstrap
+// converges the database to it on every start.
+type Manifest struct {
+	Permissions    []string       `json:"permissions"`
+	Roles          []ManifestRole `json:"roles"`
+	SuperAdminRole string         `json:"superadmin_role"`
+}
+
+type ManifestRole struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// LoadManifest reads the manifest at path, or the embedded one when path is
+// empty, and adds the known permissions to the ones it declares
+func LoadManifest(path string, known []string) (*Manifest, error) {
+	data := defaultManifest
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := new(Manifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap manifest: %w", err)
+	}
+
+	for _, p := range known {
+		if !slices.Contains(manifest.Permissions, p) {
+			manifest.Permissions = append(manifest.Permissions, p)
+		}
+	}
+
+	for _, role := range manifest.Roles {
+		for _, p := range role.Permissions {
+			if p != AllPermissions && !slices.Contains(manifest.Permissions, p) {
+				return nil, fmt.Errorf("role %s uses undeclared permission %s", role.Name, p)
+			}
+		}
+	}
+
+	return manifest, nil
+}
+
+// RolePermissions expands the wildcard of a manifest role for an
+// organization, roles outside the default one get no platform permissions
+func (m *Manifest) RolePermissions(role ManifestRole, organizationID int32) []string {
+	permissions := role.Permissions
+	if slices.Contains(role.Permissions, AllPermissions) {
+		permissions = m.Permissions
+	}
+	return tenantPermissions(organizationID, permissions)
+}
+
+// Bootstrap upserts the manifest permissions and the roles of every
+// organization and creates the initial superadmin of the default one from
+// config. It is safe to run on every start.
+func Bootstrap(ctx context.Context, db *pgxpool.Pool, cfg *Config, logger *slog.Logger, manifest *Manifest, hasher *PasswordHasher, passwords *PasswordPolicy) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repository.New(db).WithTx(tx)
+	for _, p := range manifest.Permissions {
+		if err := qtx.UpsertPermission(ctx, p); err != nil {
+			return fmt.Errorf("failed to upsert permission %s: %w", p, err)
+		}
+	}
+
+	orgs, err := qtx.ListOrganizations(ctx)
+	if err != nil {
+		return err
+	}
+	var roles map[string]repository.Role
+	for _, org := range orgs {
+		orgRoles, err := upsertManifestRoles(ctx, qtx, manifest, org.ID)
+		if err != nil {
+			return err
+		}
+		if org.ID == DefaultOrganizationID {
+			roles = orgRoles
+		}
+	}
+
+	if err := bootstrapSuperAdmin(ctx, qtx, cfg, logger, roles[manifest.SuperAdminRole], hasher, passwords); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// upsertManifestRoles converges the roles of an organization to the manifest
+// and returns them by name
+func upsertManifestRoles(ctx context.Context, qtx *repository.Queries, manifest *Manifest, organizationID int32) (map[string]repository.Role, error) {
+	roles := make(map[string]repository.Role)
+	for _, r := range manifest.Roles {
+		role, err := qtx.UpsertRole(ctx, repository.UpsertRoleParams{
+			OrganizationID: organizationID,
+			RoleName:       r.Name,
+			Permissions:    manifest.RolePermissions(r, organizationID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upsert role %s: %w", r.Name, err)
+		}
+		roles[role.RoleName] = role
+	}
+	return roles, nil
+}
+
+func bootstrapSuperAdmin(ctx context.Context, qtx *repository.Queries, cfg *Config, logger *slog.Logger, role repository.Role, hasher *PasswordHasher, passwords *PasswordPolicy) error {
+	if cfg.SuperAdminEmail == "" || cfg.SuperAdminPassword == "" {
+		logger.Warn("SUPERADMIN_EMAIL or SUPERADMIN_PASSWORD not set, skipping superadmin creation")
+		return nil
+	}
+
+	if role.ID == 0 {
+		return errors.New("bootstrap manifest does not declare the superadmin role")
+	}
+
+	if err := passwords.Validate(cfg.SuperAdminPassword, cfg.SuperAdminUsername, cfg.SuperAdminEmail); err != nil {
+		return fmt.Errorf("superadmin password does not satisfy the password policy: %w", err)
+	}
+
+	hashedPassword, err := hasher.Hash(cfg.SuperAdminPassword)
+	if err != nil {
+		return err
+	}
+
+	// existing accounts are left alone so a rotated password is never reset
+	return qtx.CreateUserIfNotExists(ctx, repository.CreateUserIfNotExistsParams{
+		OrganizationID: DefaultOrganizationID,
+		Username:       cfg.SuperAdminUsername,
+		Email:          cfg.SuperAdminEmail,
+		Password:       hashedPassword,
+		Role:           int64(role.ID),
+		IsActive:       pgtype.Bool{Bool: true, Valid: true},
+		IsVerified:     pgtype.Bool{Bool: true, Valid: true},
+		FirstName:      pgtype.Text{String: "Super", Valid: true},
+		LastName:       pgtype.Text{String: "Admin", Valid: true},
+	})
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	// UnusablePassword never matches a hash, users holding it have to set a
+	// password through a reset link before they can log in
+	UnusablePassword = "!"
+
+	// maxImportLine bounds a single ndjson line
+	maxImportLine = 1024 * 1024
+)
+
+var ErrUnknownFormat = errors.New("format must be csv or ndjson")
+
+// exportColumns is the csv header of an export, imports accept the same file
+var exportColumns = []string{"id", "username", "email", "first_name", "last_name", "phone_number", "role", "is_active", "is_verified", "created_at"}
+
+// bulkFormat picks the format from the format query param, falling back on
+// the content type of the request
+func bulkFormat(c echo.Context) string {
+	if format := c.QueryParam("format"); format != "" {
+		return format
+	}
+	if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "csv") {
+		return FormatCSV
+	}
+	return FormatNDJSON
+}
+
+// userRowReader streams import rows. A non nil rowErr only invalidates the
+// current row, err stops the import and is io.EOF at the end of the input.
+type userRowReader interface {
+	Next() (row *ImportUserRow, line int, rowErr error, err error)
+}
+
+func newUserRowReader(format string, r io.Reader) (userRowReader, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv header: %w", err)
+		}
+		columns := make(map[string]int)
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["email"]; !ok {
+			return nil, errors.New("csv header must contain an email column")
+		}
+		return &csvRowReader{reader: reader, columns: columns, line: 1}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+		return &ndjsonRowReader{scanner: scanner}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func (r *csvRowReader) Next() (*ImportUserRow, int, error, error) {
+	record, err := r.reader.Read()
+	r.line++
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, r.line, parseErr.Err, nil
+	}
+	if err != nil {
+		return nil, r.line, nil, err
+	}
+
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	// columns missing from the header stay nil so updates keep their values
+	optional := func(name string) *string {
+		if _, ok := r.columns[name]; !ok {
+			return nil
+		}
+		value := field(name)
+		return &value
+	}
+
+	row := &ImportUserRow{
+		Username:    field("username"),
+		Email:       field("email"),
+		Password:    field("password"),
+		FirstName:   optional("first_name"),
+		LastName:    optional("last_name"),
+		PhoneNumber: optional("phone_number"),
+		Role:        field("role"),
+	}
+
+	if value := field("is_active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, r.line, errors.New("is_active must be true or false"), nil
+		}
+		row.IsActive = &active
+	}
+
+	return row, r.line, nil, nil
+}
+
+type ndjsonRowReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonRowReader) Next() (*ImportUserRow, int, error, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := new(ImportUserRow)
+		if err := json.Unmarshal([]byte(text), row); err != nil {
+			return nil, r.line, err, nil
+		}
+		return row, r.line, nil, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, r.line, nil, err
+	}
+	return nil, r.line, nil, io.EOF
+}
+
+// importText is the column value of an import, or current when the row
+// leaves the column out
+func importText(value *string, current pgtype.Text) pgtype.Text {
+	if value == nil {
+		return current
+	}
+	return pgtype.Text{String: *value, Valid: *value != ""}
+}
+
+// importUser creates the row's user or updates the live user with the same
+// email. Updates only overwrite the columns the row carries, passwords are
+// only touched when the row has one.
+func (h *AuthHandler) importUser(c echo.Context, q *repository.Queries, roles map[string]int32, row *ImportUserRow) (repository.User, bool, error) {
+	var user repository.User
+
+	if err := c.Validate(row); err != nil {
+		return user, false, err
+	}
+
+	roleID, ok := roles[row.Role]
+	if !ok {
+		return user, false, fmt.Errorf("unknown role %s", row.Role)
+	}
+
+	hashedPassword := ""
+	if row.Password != "" {
+		if err := h.Passwords.Validate(row.Password, row.Username, row.Email); err != nil {
+			return user, false, err
+		}
+
+		var err error
+		hashedPassword, err = h.Hasher.Hash(row.Password)
+		if err != nil {
+			return user, false, err
+		}
+	}
+
+	existing, err := q.GetLiveUserByEmail(h.Ctx, repository.GetLiveUserByEmailParams{
+		OrganizationID: tenantID(c),
+		Email:          row.Email,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return user, false, DBError(err, "user")
+	}
+	created := err != nil
+
+	// new users are active unless the row says otherwise
+	isActive := pgtype.Bool{Bool: true, Valid: true}
+	if !created {
+		isActive = existing.IsActive
+	}
+	if row.IsActive != nil {
+		isActive = pgtype.Bool{Bool: *row.IsActive, Valid: true}
+	}
+
+	phone := existing.PhoneNumber
+	if row.PhoneNumber != nil {
+		phone = importText(row.PhoneNumber, phone)
+		if err := h.normalizePhoneNumber(&phone); err != nil {
+			return user, false, err
+		}
+	}
+
+	if created {
+		password := hashedPassword
+		if password == "" {
+			password = UnusablePassword
+		}
+
+		// imported users are vouched for by the admin running the import
+		user, err = q.CreateUser(h.Ctx, repository.CreateUserParams{
+			OrganizationID: tenantID(c),
+			Username:       row.Username,
+			Email:          row.Email,
+			Password:       password,
+			FirstName:      importText(row.FirstName, pgtype.Text{}),
+			LastName:       importText(row.LastName, pgtype.Text{}),
+			PhoneNumber:    phone,
+			IsActive:       isActive,
+			IsVerified:     pgtype.Bool{Bool: true, Valid: true},
+			Role:           int64(roleID),
+		})
+		if err != nil {
+			return user, false, DBError(err, "user")
+		}
+
+		if hashedPassword != "" {
+			if err := h.recordPassword(q, user.ID, hashedPassword); err != nil {
+				return user, false, DBError(err, "password history")
+			}
+		}
+
+		if err = recordUserRegistered(h.Ctx, q, c, user, RegistrationSourceImport); err != nil {
+			return user, false, InternalError(err)
+		}
+		return user, true, nil
+	}
+
+	err = q.UpdateUserProfile(h.Ctx, repository.UpdateUserProfileParams{
+		ID:             existing.ID,
+		Username:       row.Username,
+		FirstName:      importText(row.FirstName, existing.FirstName),
+		LastName:       importText(row.LastName, existing.LastName),
+		PhoneNumber:    phone,
+		IsActive:       isActive,
+		Role:           int64(roleID),
+		OrganizationID: existing.OrganizationID,
+	})
+	if err != nil {
+		return existing, false, DBError(err, "user")
+	}
+
+	if hashedPassword != "" {
+		if err := h.checkPasswordReuse(existing.ID, row.Password); err != nil {
+			return existing, false, err
+		}
+
+		err = q.UpdateUserPassword(h.Ctx, repository.UpdateUserPasswordParams{
+			ID:             existing.ID,
+			Password:       hashedPassword,
+			OrganizationID: existing.OrganizationID,
+		})
+		if err != nil {
+			return existing, false, DBError(err, "user")
+		}
+
+		if err := h.recordPassword(q, existing.ID, hashedPassword); err != nil {
+			return existing, false, DBError(err, "password history")
+		}
+	}
+
+	if existing.IsActive.Bool && !isActive.Bool {
+		if err := q.RevokeUserSessions(h.Ctx, existing.ID); err != nil {
+			return existing, false, DBError(err, "session")
+		}
+	}
+
+	if err = recordUserChanges(h.Ctx, q, c, existing, int64(roleID), isActive.Bool); err != nil {
+		return existing, false, InternalError(err)
+	}
+
+	return existing, false, nil
+}
+
+// sendImportInvitation mails a reset password token so an imported user
+// without a password can set one
+func (h *AuthHandler) sendImportInvitation(user repository.User) error {
+	token, err := h.createPasswordReset(user)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("An account has been created for you, use this token to set your password: %s", token)
+	return SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, user.Email, "Welcome", body)
+}
+
+// bulk handlers
+
+// ImportUsers streams a csv or ndjson body of users. Every row runs in its own
+// savepoint so a bad row is reported without failing the others, and dry runs
+// roll the whole transaction back.
+func (h *AuthHandler) ImportUsers(c echo.Context) error {
+	dryRun := c.QueryParam("dry_run") == "true"
+	invite := c.QueryParam("invite") == "true"
+
+	reader, err := newUserRowReader(bulkFormat(c), c.Request().Body)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	roleList, err := h.Repo.ListRoles(h.Ctx, tenantID(c))
+	if err != nil {
+		return DBError(err, "role")
+	}
+	roles := make(map[string]int32)
+	for _, role := range roleList {
+		roles[role.RoleName] = role.ID
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	report := &ImportReport{DryRun: dryRun, Errors: make([]ImportRowError, 0)}
+	invitees := make([]repository.User, 0)
+	for {
+		row, line, rowErr, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		}
+
+		report.Total++
+		var user repository.User
+		var created bool
+		if rowErr == nil {
+			user, created, rowErr = h.importRow(c, tx, roles, row)
+		}
+
+		if rowErr != nil {
+			report.Failed++
+			rowError := ImportRowError{Line: line, Error: rowErr.Error()}
+			var domainErr *DomainError
+			if errors.As(rowErr, &domainErr) {
+				rowError.Error = domainErr.Message
+			}
+			if row != nil {
+				rowError.Email = row.Email
+			}
+			report.Errors = append(report.Errors, rowError)
+			continue
+		}
+
+		if created {
+			report.Created++
+			if invite && row.Password == "" {
+				invitees = append(invitees, user)
+			}
+		} else {
+			report.Updated++
+		}
+	}
+
+	if dryRun {
+		return NewResponse(c, "success", report, "", http.StatusOK)
+	}
+
+	if err := tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	for _, user := range invitees {
+		if err := h.sendImportInvitation(user); err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Email: user.Email, Error: "invitation not sent: " + err.Error()})
+			continue
+		}
+		report.Invited++
+	}
+
+	return NewResponse(c, "success", report, "", http.StatusOK)
+}
+
+func (h *AuthHandler) importRow(c echo.Context, tx pgx.Tx, roles map[string]int32, row *ImportUserRow) (repository.User, bool, error) {
+	savepoint, err := tx.Begin(h.Ctx)
+	if err != nil {
+		return repository.User{}, false, err
+	}
+	defer savepoint.Rollback(h.Ctx)
+
+	user, created, err := h.importUser(c, h.Repo.WithTx(savepoint), roles, row)
+	if err != nil {
+		return user, false, err
+	}
+
+	return user, created, savepoint.Commit(h.Ctx)
+}
+
+// ExportUsers streams the users matching the listing filters as csv or ndjson
+func (h *AuthHandler) ExportUsers(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = FormatCSV
+	}
+	if format != FormatCSV && format != FormatNDJSON {
+		return NewResponse(c, "failed", nil, ErrUnknownFormat.Error(), http.StatusBadRequest)
+	}
+
+	users, err := h.listUsers(c)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	roleList, err := h.Repo.ListRoles(h.Ctx, tenantID(c))
+	if err != nil {
+		return DBError(err, "role")
+	}
+	roles := make(map[int64]string)
+	for _, role := range roleList {
+		roles[int64(role.ID)] = role.RoleName
+	}
+
+	res := c.Response()
+	contentType := "text/csv"
+	if format == FormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="users.%s"`, format))
+	res.WriteHeader(http.StatusOK)
+
+	if format == FormatNDJSON {
+		encoder := json.NewEncoder(res)
+		for _, user := range users {
+			if err := encoder.Encode(newExportUserRow(user, roles)); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	}
+
+	writer := csv.NewWriter(res)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, user := range users {
+		row := newExportUserRow(user, roles)
+		err := writer.Write([]string{
+			strconv.Itoa(int(row.ID)),
+			row.Username,
+			row.Email,
+			row.FirstName,
+			row.LastName,
+			row.PhoneNumber,
+			row.Role,
+			strconv.FormatBool(row.IsActive),
+			strconv.FormatBool(row.IsVerified),
+			row.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	res.Flush()
+	return writer.Error()
+}
+
+func newExportUserRow(user repository.User, roles map[int64]string) ExportUserRow {
+	row := ExportUserRow{
+		ID:          user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		FirstName:   user.FirstName.String,
+		LastName:    user.LastName.String,
+		PhoneNumber: user.PhoneNumber.String,
+		Role:        roles[user.Role],
+		IsActive:    user.IsActive.Bool,
+		IsVerified:  user.IsVerified.Bool,
+	}
+	if user.CreatedAt.Valid {
+		row.CreatedAt = user.CreatedAt.Time.Format(time.RFC3339)
+	}
+	return row
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodGet, path: "/health"}, nil)
+}
+
+// Register creates an account, depending on the service's registration mode
+// it may need an approval before it can log in
+func (c *Client) Register(ctx context.Context, data RegisterRequest) (*User, error) {
+	user := new(User)
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/register", body: data}, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login stores the tokens of the new session. When the result asks for a
+// second factor no tokens are stored until VerifyLogin succeeds.
+func (c *Client) Login(ctx context.Context, email, password, device string) (*LoginResult, error) {
+	body := map[string]string{"email": email, "password": password, "device": device}
+	return c.login(ctx, "/login", body)
+}
+
+// VerifyLogin answers the challenge of a Login with the code sent by sms
+func (c *Client) VerifyLogin(ctx context.Context, challenge, code, device string) (*LoginResult, error) {
+	body := map[string]string{"challenge": challenge, "code": code, "device": device}
+	return c.login(ctx, "/login/verify", body)
+}
+
+func (c *Client) login(ctx context.Context, path string, body any) (*LoginResult, error) {
+	result := new(LoginResult)
+	if err := c.do(ctx, request{method: http.MethodPost, path: path, body: body}, result); err != nil {
+		return nil, err
+	}
+	if result.Token != "" {
+		c.SetTokens(Tokens{AccessToken: result.Token, RefreshToken: result.RefreshToken})
+	}
+	return result, nil
+}
+
+// Refresh exchanges the stored refresh token for a new access token and
+// stores the rotated refresh token, calls do this on their own when the
+// access token expired
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, c.Tokens().AccessToken)
+}
+
+// Logout ends the session on the service and forgets the tokens
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/logout"}, nil); err != nil {
+		return err
+	}
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	body := map[string]string{"email": email}
+	return c.do(ctx, request{method: http.MethodPost, path: "/forgot-password", body: body}, nil)
+}
+
+func (c *Client) ResetPassword(ctx context.Context, token, password string) error {
+	body := map[string]string{"token": token, "password": password}
+	return c.do(ctx, request{method: http.MethodPost, path: "/reset-password", body: body}, nil)
+}
+
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+	query := url.Values{"token": {token}}
+	return c.do(ctx, request{method: http.MethodGet, path: "/verify-email", query: query}, nil)
+}
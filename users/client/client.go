@@ -0,0 +1,284 @@
+// Package client is a typed client for the users service. It keeps the
+// tokens of the last login, refreshes the access token when it expires and
+// retries idempotent calls on transient failures.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BasePath is where the users service mounts its routes
+	BasePath = "/v1/auth"
+
+	ApiKeyHeader = "X-API-Key"
+	TenantHeader = "X-Tenant"
+
+	DefaultMaxRetries = 2
+	DefaultRetryWait  = 200 * time.Millisecond
+)
+
+// Tokens are the credentials returned by a login
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// MaxRetries is how often an idempotent call is repeated after a network
+	// error or a 429, 502, 503 or 504, waits double from RetryWait
+	MaxRetries int
+	RetryWait  time.Duration
+
+	// OnTokens is called whenever the client stores new tokens, e.g. to
+	// persist them
+	OnTokens func(Tokens)
+
+	// Tenant is the slug of the organization to act in, empty for the
+	// organization of the credentials
+	Tenant string
+
+	mu     sync.Mutex
+	tokens Tokens
+	apiKey string
+
+	// refreshMu is held for a whole refresh, so callers failing with the
+	// same stale token wait for it instead of starting their own
+	refreshMu sync.Mutex
+}
+
+// New returns a client for the service at baseURL, e.g. "http://users:8000"
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: DefaultMaxRetries,
+		RetryWait:  DefaultRetryWait,
+	}
+}
+
+// NewWithApiKey returns a client that authenticates every call with key,
+// which is how services call the users service
+func NewWithApiKey(baseURL, key string) *Client {
+	c := New(baseURL)
+	c.apiKey = key
+	return c
+}
+
+// SetTokens replaces the stored tokens, e.g. with ones persisted earlier
+func (c *Client) SetTokens(tokens Tokens) {
+	c.mu.Lock()
+	c.tokens = tokens
+	c.mu.Unlock()
+
+	if c.OnTokens != nil {
+		c.OnTokens(tokens)
+	}
+}
+
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// envelope mirrors the service's Response
+type envelope struct {
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	Err       string          `json:"error"`
+	ErrorCode string          `json:"error_code"`
+	Errors    []FieldError    `json:"errors"`
+	Code      int             `json:"code"`
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+}
+
+// do sends req and decodes the data of the response into out, which may be
+// nil. A 401 triggers one token refresh and one more attempt.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	token := c.Tokens().AccessToken
+	env, err := c.send(ctx, req, body)
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized && c.canRefresh(req) {
+		if refreshErr := c.refresh(ctx, token); refreshErr != nil {
+			return err
+		}
+		env, err = c.send(ctx, req, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(env.Data) == 0 || string(env.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("decode %s %s: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send performs req, idempotent calls are repeated on transient failures
+func (c *Client) send(ctx context.Context, req request, body []byte) (*envelope, error) {
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		env, err := c.sendOnce(ctx, req, body)
+		if err == nil || !idempotent(req.method) || attempt >= c.MaxRetries || !retryable(err) {
+			return env, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// sendOnce performs a single attempt, answers with an error status become *Error
+func (c *Client) sendOnce(ctx context.Context, req request, body []byte) (*envelope, error) {
+	u := c.BaseURL + BasePath + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.Tenant != "" {
+		httpReq.Header.Set(TenantHeader, c.Tenant)
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set(ApiKeyHeader, c.apiKey)
+	} else if token := c.Tokens().AccessToken; token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	env := new(envelope)
+	decodeErr := json.NewDecoder(res.Body).Decode(env)
+	if res.StatusCode >= http.StatusBadRequest {
+		if decodeErr != nil {
+			return nil, &Error{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		}
+		return nil, newError(res.StatusCode, env)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("decode %s %s: %w", req.method, req.path, decodeErr)
+	}
+	return env, nil
+}
+
+// canRefresh skips the refresh for calls that hand out tokens themselves
+func (c *Client) canRefresh(req request) bool {
+	if c.apiKey != "" || c.Tokens().RefreshToken == "" {
+		return false
+	}
+	switch req.path {
+	case "/login", "/login/verify", "/refresh-token":
+		return false
+	}
+	return true
+}
+
+// refresh exchanges the refresh token for a new access token and the refresh
+// token replacing it, the service accepts each refresh token once. Concurrent
+// callers that failed with the same stale token share one refresh.
+func (c *Client) refresh(ctx context.Context, staleToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens := c.Tokens()
+	if tokens.AccessToken != staleToken {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{"refresh_token": tokens.RefreshToken})
+	if err != nil {
+		return err
+	}
+
+	env, err := c.sendOnce(ctx, request{method: http.MethodPost, path: "/refresh-token"}, body)
+	if err != nil {
+		return err
+	}
+
+	var data struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return err
+	}
+
+	tokens.AccessToken = data.Token
+	if data.RefreshToken != "" {
+		tokens.RefreshToken = data.RefreshToken
+	}
+	c.SetTokens(tokens)
+	return nil
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// FieldError mirrors a field level validation error of the service
+type FieldError struct {
+	Field   string         `json:"field"`
+	Rule    string         `json:"rule"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// Error is a failed call, it carries the error of the Response envelope.
+// ErrorCode is stable and meant for programs, e.g. "email_taken".
+type Error struct {
+	StatusCode int
+	Message    string
+	ErrorCode  string
+	Fields     []FieldError
+}
+
+func (e *Error) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("users: %d %s: %s", e.StatusCode, e.ErrorCode, e.Message)
+	}
+	return fmt.Sprintf("users: %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches errors of the same status, so errors.Is(err, ErrConflict) holds
+// for an email_taken conflict too
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.StatusCode == e.StatusCode
+}
+
+// kinds of errors, for use with errors.Is
+var (
+	ErrBadRequest   = &Error{StatusCode: http.StatusBadRequest, ErrorCode: "bad_request", Message: "bad request"}
+	ErrUnauthorized = &Error{StatusCode: http.StatusUnauthorized, ErrorCode: "unauthorized", Message: "unauthorized"}
+	ErrForbidden    = &Error{StatusCode: http.StatusForbidden, ErrorCode: "forbidden", Message: "forbidden"}
+	ErrNotFound     = &Error{StatusCode: http.StatusNotFound, ErrorCode: "not_found", Message: "not found"}
+	ErrConflict     = &Error{StatusCode: http.StatusConflict, ErrorCode: "conflict", Message: "conflict"}
+	ErrValidation   = &Error{StatusCode: http.StatusUnprocessableEntity, ErrorCode: "validation_failed", Message: "validation failed"}
+)
+
+func newError(status int, env *envelope) *Error {
+	message := env.Err
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &Error{
+		StatusCode: status,
+		Message:    message,
+		ErrorCode:  env.ErrorCode,
+		Fields:     env.Errors,
+	}
+}
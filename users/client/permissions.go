@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListPermissions lists permissions, a non empty group keeps only those named
+// "<group>:..."
+func (c *Client) ListPermissions(ctx context.Context, group string, opts ListOptions) ([]Permission, error) {
+	query := opts.query()
+	if group != "" {
+		query.Set("group", group)
+	}
+
+	var permissions []Permission
+	err := c.do(ctx, request{method: http.MethodGet, path: "/permissions", query: query}, &permissions)
+	return permissions, err
+}
+
+func (c *Client) CreatePermissions(ctx context.Context, names ...string) error {
+	body := map[string][]string{"permissions": names}
+	return c.do(ctx, request{method: http.MethodPost, path: "/permissions", body: body}, nil)
+}
+
+// DeletePermission soft deletes the permission, see RestorePermission
+func (c *Client) DeletePermission(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/permissions/%d", id)}, nil)
+}
+
+func (c *Client) RestorePermission(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/permissions/%d/restore", id)}, nil)
+}
+
+// PermissionRoutes lists the routes each permission known to the service unlocks
+func (c *Client) PermissionRoutes(ctx context.Context) ([]PermissionRoutes, error) {
+	var routes []PermissionRoutes
+	err := c.do(ctx, request{method: http.MethodGet, path: "/permissions/routes"}, &routes)
+	return routes, err
+}
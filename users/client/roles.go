@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func (c *Client) ListRoles(ctx context.Context, opts ListOptions) ([]Role, error) {
+	var roles []Role
+	err := c.do(ctx, request{method: http.MethodGet, path: "/roles", query: opts.query()}, &roles)
+	return roles, err
+}
+
+func (c *Client) GetRole(ctx context.Context, id int32) (*Role, error) {
+	role := new(Role)
+	if err := c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/roles/%d", id)}, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (c *Client) CreateRole(ctx context.Context, data RoleRequest) (*Role, error) {
+	role := new(Role)
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/roles", body: data}, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (c *Client) UpdateRole(ctx context.Context, id int32, data RoleRequest) error {
+	return c.do(ctx, request{method: http.MethodPut, path: fmt.Sprintf("/roles/%d", id), body: data}, nil)
+}
+
+// DeleteRole soft deletes the role, see RestoreRole
+func (c *Client) DeleteRole(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/roles/%d", id)}, nil)
+}
+
+func (c *Client) RestoreRole(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/roles/%d/restore", id)}, nil)
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Time is a timestamp that may be null. The service sends timestamps
+// without a zone, they are in UTC.
+type Time struct {
+	time.Time
+	Valid bool
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*t = Time{}
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		parsed, err := time.Parse(layout, *s)
+		if err == nil {
+			*t = Time{Time: parsed, Valid: true}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q", *s)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time)
+}
+
+type User struct {
+	ID              int32   `json:"id"`
+	OrganizationID  int32   `json:"organization_id"`
+	Username        string  `json:"username"`
+	Email           string  `json:"email"`
+	FirstName       *string `json:"first_name"`
+	LastName        *string `json:"last_name"`
+	PhoneNumber     *string `json:"phone_number"`
+	PhoneVerified   *bool   `json:"phone_verified"`
+	SmsTwoFactor    *bool   `json:"sms_two_factor"`
+	IsActive        *bool   `json:"is_active"`
+	IsVerified      *bool   `json:"is_verified"`
+	ApprovalPending *bool   `json:"approval_pending"`
+	Role            int64   `json:"role"`
+	CreatedAt       Time    `json:"created_at"`
+	UpdatedAt       Time    `json:"updated_at"`
+	DeletedAt       Time    `json:"deleted_at"`
+}
+
+type RegisterRequest struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+}
+
+type CreateUserRequest struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	IsActive    bool   `json:"is_active"`
+	IsVerified  bool   `json:"is_verified"`
+	Role        int64  `json:"role"`
+}
+
+// UpdateUserRequest replaces the user's profile, Password, IsActive and
+// IsVerified are only changed when set
+type UpdateUserRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password,omitempty"`
+	FirstName   string `json:"first_name,omitempty"`
+	LastName    string `json:"last_name,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	IsActive    *bool  `json:"is_active,omitempty"`
+	IsVerified  *bool  `json:"is_verified,omitempty"`
+	Role        int64  `json:"role"`
+}
+
+// LoginResult is either a session, or a challenge to answer with
+// VerifyLogin when the user has sms two factor enabled
+type LoginResult struct {
+	Token             string `json:"token"`
+	RefreshToken      string `json:"refresh_token"`
+	User              *User  `json:"user"`
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Challenge         string `json:"challenge"`
+}
+
+type Role struct {
+	ID          int32    `json:"id"`
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   Time     `json:"created_at"`
+	UpdatedAt   Time     `json:"updated_at"`
+	DeletedAt   Time     `json:"deleted_at"`
+}
+
+type RoleRequest struct {
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	ID        int32  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt Time   `json:"created_at"`
+	UpdatedAt Time   `json:"updated_at"`
+	DeletedAt Time   `json:"deleted_at"`
+}
+
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// PermissionRoutes lists the routes a permission unlocks
+type PermissionRoutes struct {
+	Permission string      `json:"permission"`
+	Routes     []RouteInfo `json:"routes"`
+}
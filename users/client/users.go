@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListOptions filter listings, Deleted lists soft deleted records instead
+// of live ones
+type ListOptions struct {
+	Deleted bool
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.Deleted {
+		query.Set("deleted", "true")
+	}
+	return query
+}
+
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) ([]User, error) {
+	var users []User
+	err := c.do(ctx, request{method: http.MethodGet, path: "/users", query: opts.query()}, &users)
+	return users, err
+}
+
+func (c *Client) GetUser(ctx context.Context, id int32) (*User, error) {
+	user := new(User)
+	if err := c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/users/%d", id)}, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *Client) CreateUser(ctx context.Context, data CreateUserRequest) (*User, error) {
+	user := new(User)
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/users", body: data}, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *Client) UpdateUser(ctx context.Context, id int32, data UpdateUserRequest) error {
+	return c.do(ctx, request{method: http.MethodPut, path: fmt.Sprintf("/users/%d", id), body: data}, nil)
+}
+
+// DeleteUser soft deletes the user, see RestoreUser
+func (c *Client) DeleteUser(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/users/%d", id)}, nil)
+}
+
+func (c *Client) RestoreUser(ctx context.Context, id int32) error {
+	return c.do(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/users/%d/restore", id)}, nil)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headers of a webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// DefaultWebhookTolerance is how old a delivery VerifyWebhook accepts,
+	// older ones may be replayed
+	DefaultWebhookTolerance = 5 * time.Minute
+)
+
+var (
+	ErrWebhookSignature = errors.New("users: invalid webhook signature")
+	ErrWebhookTimestamp = errors.New("users: webhook timestamp outside the tolerance")
+)
+
+// SignWebhook is the signature of a delivery, "v1=" and the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the subscription's secret
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature and the age of a delivery, body has to
+// be the raw request body. While a secret is rotated, verify with the old and
+// the new one.
+func VerifyWebhook(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return ErrWebhookTimestamp
+	}
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrWebhookTimestamp
+	}
+
+	want := SignWebhook(secret, timestamp, body)
+	for _, signature := range strings.Split(header.Get(WebhookSignatureHeader), ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(signature)), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrWebhookSignature
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"users/client"
+)
+
+// newTestClient serves the real router, wrap may intercept requests before
+// they reach it
+func newTestClient(t *testing.T, wrap func(next http.Handler) http.Handler) *client.Client {
+	t.Helper()
+
+	var handler http.Handler = newTestServer(t).Echo
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	c := client.New(ts.URL)
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+func TestClientHealth(t *testing.T) {
+	c := newTestClient(t, nil)
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+}
+
+func TestClientValidationError(t *testing.T) {
+	c := newTestClient(t, nil)
+
+	_, err := c.Register(context.Background(), client.RegisterRequest{})
+	if !errors.Is(err, client.ErrValidation) {
+		t.Fatalf("Register: got %v, want a validation error", err)
+	}
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Register: %T is not a *client.Error", err)
+	}
+	if apiErr.ErrorCode != CodeValidationFailed {
+		t.Errorf("error code %q, want %q", apiErr.ErrorCode, CodeValidationFailed)
+	}
+
+	fields := map[string]string{}
+	for _, field := range apiErr.Fields {
+		fields[field.Field] = field.Rule
+	}
+	for _, field := range []string{"username", "email", "password"} {
+		if fields[field] != "required" {
+			t.Errorf("field %s: rule %q, want required", field, fields[field])
+		}
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	c := newTestClient(t, nil)
+	c.SetTokens(client.Tokens{AccessToken: "not-a-token"})
+
+	_, err := c.ListUsers(context.Background(), client.ListOptions{})
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("ListUsers: got %v, want unauthorized", err)
+	}
+}
+
+func TestClientRetriesIdempotentCalls(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	c := newTestClient(t, flaky)
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Health took %d calls, want 3", got)
+	}
+
+	calls.Store(0)
+	_, err := c.Register(context.Background(), client.RegisterRequest{})
+	if !errors.Is(err, &client.Error{StatusCode: http.StatusServiceUnavailable}) {
+		t.Fatalf("Register: got %v, want 503", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Register took %d calls, want 1, posts must not be retried", got)
+	}
+}
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	cfg := &Config{JWTSecret: "other-secret"}
+	stale, err := GenerateToken(cfg, AccessToken, 1, 1, DefaultOrganizationID, 1, []string{UsersListPermission})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the real router rejects the stale token, the refresh and the retried
+	// call are answered here since they need the database
+	var refreshes atomic.Int32
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/auth/refresh-token":
+				refreshes.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"message":"success","data":{"token":"fresh","refresh_token":"rotated"},"code":200}`))
+			case r.Header.Get("Authorization") == "Bearer fresh":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"message":"success","data":[],"code":200}`))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+
+	c := newTestClient(t, auth)
+	var stored client.Tokens
+	c.OnTokens = func(tokens client.Tokens) { stored = tokens }
+	c.SetTokens(client.Tokens{AccessToken: stale, RefreshToken: "refresh"})
+
+	if _, err := c.ListUsers(context.Background(), client.ListOptions{}); err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("%d refreshes, want 1", got)
+	}
+	if stored.AccessToken != "fresh" || stored.RefreshToken != "rotated" {
+		t.Errorf("stored tokens %+v, want the fresh access and rotated refresh token", stored)
+	}
+}
+
+func TestClientSharesRefresh(t *testing.T) {
+	cfg := &Config{JWTSecret: "other-secret"}
+	stale, err := GenerateToken(cfg, AccessToken, 1, 1, DefaultOrganizationID, 1, []string{UsersListPermission})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the refresh is answered once every caller has been rejected, so all of
+	// them are waiting on it
+	const callers = 5
+	var refreshes, rejected atomic.Int32
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/v1/auth/refresh-token":
+				refreshes.Add(1)
+				for deadline := time.Now().Add(5 * time.Second); rejected.Load() < callers && time.Now().Before(deadline); {
+					time.Sleep(time.Millisecond)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"message":"success","data":{"token":"fresh"},"code":200}`))
+			case r.Header.Get("Authorization") == "Bearer fresh":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"message":"success","data":[],"code":200}`))
+			default:
+				next.ServeHTTP(w, r)
+				rejected.Add(1)
+			}
+		})
+	}
+
+	c := newTestClient(t, auth)
+	c.SetTokens(client.Tokens{AccessToken: stale, RefreshToken: "refresh"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.ListUsers(context.Background(), client.ListOptions{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ListUsers: %v", err)
+		}
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("%d refreshes, want 1", got)
+	}
+}
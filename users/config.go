@@ -3,12 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
 	AppAddr       string
+	GrpcAddr      string
 	DbHost        string
 	DbPort        string
 	DbUser        string
@@ -17,6 +23,93 @@ type Config struct {
 	JWTSecret     string
 	EmailFrom     string
 	EmailPassword string
+
+	// jwt claims
+	JWTIssuer   string
+	JWTAudience []string
+	JWTLeeway   time.Duration
+
+	// token lifetimes per token type
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration
+	VerifyEmailTokenTTL   time.Duration
+	ResetPasswordTokenTTL time.Duration
+	EmailChangeTokenTTL   time.Duration
+
+	// password policy
+	PasswordMinLength     int
+	PasswordMaxLength     int
+	PasswordRequireUpper  bool
+	PasswordRequireLower  bool
+	PasswordRequireDigit  bool
+	PasswordRequireSymbol bool
+	PasswordHistorySize   int
+	PasswordMaxAge        time.Duration
+	BreachedPasswordsFile string
+
+	// password hashing
+	PasswordHashAlgorithm string
+	BcryptCost            int
+	Argon2Time            uint32
+	Argon2Memory          uint32
+	Argon2Threads         uint8
+
+	// phone verification
+	SmsProvider             string
+	PhoneDefaultCountryCode string
+	OtpTTL                  time.Duration
+	OtpMaxAttempts          int
+	OtpResendCooldown       time.Duration
+	OtpMaxPerWindow         int
+	OtpWindow               time.Duration
+
+	// registration
+	RegistrationMode           string
+	RegistrationAllowedDomains []string
+	RegistrationApproval       bool
+	DefaultRole                string
+
+	// invitations
+	InvitationTTL time.Duration
+	InvitationURL string
+
+	// multi-tenancy, organizations are served as subdomains of TenantDomain,
+	// e.g. acme.auth.example.com, when it is set
+	TenantDomain string
+
+	// soft delete
+	SoftDeleteRetention time.Duration
+	PurgeInterval       time.Duration
+
+	// domain events
+	EventPublisher    string
+	EventWebhookURL   string
+	EventTimeout      time.Duration
+	NatsURL           string
+	NatsSubjectPrefix string
+	KafkaRestURL      string
+	KafkaTopic        string
+	OutboxInterval    time.Duration
+	OutboxBatchSize   int
+	OutboxMaxBackoff  time.Duration
+	OutboxRetention   time.Duration
+
+	// webhooks
+	WebhookInterval    time.Duration
+	WebhookBatchSize   int
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	WebhookRetryBase   time.Duration
+	WebhookMaxBackoff  time.Duration
+	// WebhookAllowPrivate lets receivers live on private and loopback
+	// addresses, for development only
+	WebhookAllowPrivate bool
+
+	// bootstrap
+	BootstrapManifest  string
+	SuperAdminUsername string
+	SuperAdminEmail    string
+	SuperAdminPassword string
 }
 
 func LoadConfig() *Config {
@@ -28,6 +121,7 @@ func LoadConfig() *Config {
 
 	return &Config{
 		AppAddr:       appAddr,
+		GrpcAddr:      getEnv("GRPC_ADDR", "localhost:9000"),
 		DbHost:        os.Getenv("DB_HOST"),
 		DbPort:        os.Getenv("DB_PORT"),
 		DbUser:        os.Getenv("DB_USER"),
@@ -36,6 +130,92 @@ func LoadConfig() *Config {
 		JWTSecret:     os.Getenv("JWT_SECRET"),
 		EmailFrom:     os.Getenv("EMAIL_FROM"),
 		EmailPassword: os.Getenv("EMAIL_PASSWORD"),
+
+		JWTIssuer:   getEnv("JWT_ISSUER", "users"),
+		JWTAudience: getEnvList("JWT_AUDIENCE", []string{"users"}),
+		JWTLeeway:   getEnvDuration("JWT_LEEWAY", 30*time.Second),
+
+		AccessTokenTTL:        getEnvDuration("ACCESS_TOKEN_TTL", 10*time.Hour),
+		RefreshTokenTTL:       getEnvDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+		VerifyEmailTokenTTL:   getEnvDuration("VERIFY_EMAIL_TOKEN_TTL", 24*time.Hour),
+		ResetPasswordTokenTTL: getEnvDuration("RESET_PASSWORD_TOKEN_TTL", time.Hour),
+		EmailChangeTokenTTL:   getEnvDuration("EMAIL_CHANGE_TOKEN_TTL", 24*time.Hour),
+
+		PasswordMinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 12),
+		PasswordMaxLength:     getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordHistorySize:   getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+		PasswordMaxAge:        getEnvDuration("PASSWORD_MAX_AGE", 0),
+		BreachedPasswordsFile: os.Getenv("BREACHED_PASSWORDS_FILE"),
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", Argon2id),
+		BcryptCost:            getEnvInt("BCRYPT_COST", bcrypt.DefaultCost),
+		Argon2Time:            uint32(getEnvInt("ARGON2_TIME", 2)),
+		Argon2Memory:          uint32(getEnvInt("ARGON2_MEMORY", 19*1024)),
+		Argon2Threads:         uint8(getEnvInt("ARGON2_THREADS", 1)),
+
+		SmsProvider:             getEnv("SMS_PROVIDER", SmsProviderLog),
+		PhoneDefaultCountryCode: os.Getenv("PHONE_DEFAULT_COUNTRY_CODE"),
+		OtpTTL:                  getEnvDuration("OTP_TTL", 5*time.Minute),
+		OtpMaxAttempts:          getEnvInt("OTP_MAX_ATTEMPTS", 5),
+		OtpResendCooldown:       getEnvDuration("OTP_RESEND_COOLDOWN", time.Minute),
+		OtpMaxPerWindow:         getEnvInt("OTP_MAX_PER_WINDOW", 5),
+		OtpWindow:               getEnvDuration("OTP_WINDOW", time.Hour),
+
+		RegistrationMode:           getEnv("REGISTRATION_MODE", RegistrationOpen),
+		RegistrationAllowedDomains: getEnvList("REGISTRATION_ALLOWED_DOMAINS", nil),
+		RegistrationApproval:       getEnvBool("REGISTRATION_APPROVAL", false),
+		DefaultRole:                getEnv("DEFAULT_ROLE", "user"),
+
+		InvitationTTL: getEnvDuration("INVITATION_TTL", 72*time.Hour),
+		InvitationURL: getEnv("INVITATION_URL", appAddr+"/v1/auth/invitations/accept"),
+
+		TenantDomain: strings.ToLower(os.Getenv("TENANT_DOMAIN")),
+
+		SoftDeleteRetention: getEnvDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+		PurgeInterval:       getEnvDuration("PURGE_INTERVAL", 24*time.Hour),
+
+		EventPublisher:    getEnv("EVENT_PUBLISHER", EventPublisherLog),
+		EventWebhookURL:   os.Getenv("EVENT_WEBHOOK_URL"),
+		EventTimeout:      getEnvDuration("EVENT_TIMEOUT", 10*time.Second),
+		NatsURL:           getEnv("NATS_URL", nats.DefaultURL),
+		NatsSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "users.events"),
+		KafkaRestURL:      os.Getenv("KAFKA_REST_URL"),
+		KafkaTopic:        getEnv("KAFKA_TOPIC", "users.events"),
+		OutboxInterval:    getEnvDuration("OUTBOX_INTERVAL", time.Second),
+		OutboxBatchSize:   getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxMaxBackoff:  getEnvDuration("OUTBOX_MAX_BACKOFF", time.Hour),
+		OutboxRetention:   getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		WebhookInterval:     getEnvDuration("WEBHOOK_INTERVAL", time.Second),
+		WebhookBatchSize:    getEnvInt("WEBHOOK_BATCH_SIZE", 20),
+		WebhookTimeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+		WebhookRetryBase:    getEnvDuration("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookMaxBackoff:   getEnvDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+		WebhookAllowPrivate: getEnvBool("WEBHOOK_ALLOW_PRIVATE", false),
+
+		BootstrapManifest:  os.Getenv("BOOTSTRAP_MANIFEST"),
+		SuperAdminUsername: getEnv("SUPERADMIN_USERNAME", "superadmin"),
+		SuperAdminEmail:    os.Getenv("SUPERADMIN_EMAIL"),
+		SuperAdminPassword: readSecret("SUPERADMIN_PASSWORD"),
+	}
+}
+
+// TokenTTL returns the configured lifetime for the given token type
+func (c *Config) TokenTTL(tokenType TokenType) time.Duration {
+	switch tokenType {
+	case RefreshToken:
+		return c.RefreshTokenTTL
+	case VerifyEmailToken:
+		return c.VerifyEmailTokenTTL
+	case TwoFactorToken:
+		return c.OtpTTL
+	default:
+		return c.AccessTokenTTL
 	}
 }
 
@@ -45,3 +225,67 @@ func InitEnv() error {
 	}
 	return nil
 }
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvDuration parses values like "10h" or "30s", falling back on empty or invalid input
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList splits a comma separated value, e.g. "users,loyalty"
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// readSecret returns the value of key, or the trimmed content of the file named
+// by key_FILE, which is how docker and kubernetes secrets are usually mounted
+func readSecret(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// requestEmailChange stores newEmail as the pending address of user, mails a
+// confirmation link to it and a notice with a cancel link to the current
+// address. A newer request cancels the previous one.
+func (h *AuthHandler) requestEmailChange(c echo.Context, user repository.User, newEmail string) error {
+	if newEmail == user.Email {
+		return NewResponse(c, "failed", nil, "new email is the current email", http.StatusBadRequest)
+	}
+
+	if _, err := h.Repo.GetLiveUserByEmail(h.Ctx, repository.GetLiveUserByEmailParams{OrganizationID: user.OrganizationID, Email: newEmail}); err == nil {
+		return NewResponse(c, "failed", nil, "email is already taken", http.StatusConflict)
+	}
+
+	confirmToken, confirmHash, err := GenerateOpaqueToken()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	cancelToken, cancelHash, err := GenerateOpaqueToken()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	if err = qtx.CancelUserEmailChanges(h.Ctx, user.ID); err != nil {
+		return DBError(err, "email change")
+	}
+
+	change, err := qtx.CreateEmailChange(h.Ctx, repository.CreateEmailChangeParams{
+		UserID:           user.ID,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: confirmHash,
+		CancelTokenHash:  cancelHash,
+		ExpiresAt:        pgtype.Timestamp{Time: time.Now().Add(h.Cfg.EmailChangeTokenTTL), Valid: true},
+	})
+	if err != nil {
+		return DBError(err, "email change")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	confirmLink := fmt.Sprintf("%s/v1/auth/email-change/confirm?token=%s", h.Cfg.AppAddr, confirmToken)
+	confirmBody := fmt.Sprintf("<a href=\"%s\">Confirm your new email</a>", confirmLink)
+	if err = SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, change.NewEmail, "Confirm Email Change", confirmBody); err != nil {
+		return InternalError(err)
+	}
+
+	cancelLink := fmt.Sprintf("%s/v1/auth/email-change/cancel?token=%s", h.Cfg.AppAddr, cancelToken)
+	noticeBody := fmt.Sprintf("A change of your account email to %s was requested. If this was not you, <a href=\"%s\">cancel the change</a>.", change.NewEmail, cancelLink)
+	if err = SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, user.Email, "Email Change Requested", noticeBody); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", newEmailChangeDTO(change), "", http.StatusAccepted)
+}
+
+func newEmailChangeDTO(change repository.EmailChange) EmailChangeDTO {
+	return EmailChangeDTO{
+		NewEmail:  change.NewEmail,
+		ExpiresAt: change.ExpiresAt,
+		CreatedAt: change.CreatedAt,
+	}
+}
+
+// email change handlers
+
+// ChangeMyEmail asks for the current password so a stolen session alone
+// cannot move the account to another address
+func (h *AuthHandler) ChangeMyEmail(c echo.Context) error {
+	data := new(ChangeEmailDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "only users can change their email", http.StatusBadRequest)
+	}
+
+	if ok, _ := h.Hasher.Verify(data.Password, user.Password); !ok {
+		return NewResponse(c, "failed", nil, "invalid password", http.StatusUnauthorized)
+	}
+
+	return h.requestEmailChange(c, user, data.Email)
+}
+
+func (h *AuthHandler) GetMyEmailChange(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	change, err := h.Repo.GetPendingUserEmailChange(h.Ctx, int32(userID))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "no pending email change", http.StatusNotFound)
+	}
+
+	return NewResponse(c, "success", newEmailChangeDTO(change), "", http.StatusOK)
+}
+
+func (h *AuthHandler) ChangeUserEmail(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	data := new(AdminChangeEmailDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	user, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	return h.requestEmailChange(c, user, data.Email)
+}
+
+// ConfirmEmailChange swaps the address once the new one proved it receives mail
+func (h *AuthHandler) ConfirmEmailChange(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return NewResponse(c, "failed", nil, "token is required", http.StatusBadRequest)
+	}
+
+	change, err := h.Repo.GetPendingEmailChangeByConfirmHash(h.Ctx, HashApiKey(token))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "invalid or expired token", http.StatusBadRequest)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	confirmed, err := qtx.ConfirmEmailChange(h.Ctx, change.ID)
+	if err != nil {
+		return DBError(err, "email change")
+	}
+	if confirmed == 0 {
+		return NewResponse(c, "failed", nil, "invalid or expired token", http.StatusBadRequest)
+	}
+
+	// the token proves access to the account, whatever the tenant of the link
+	organizationID, err := qtx.GetUserOrganizationID(h.Ctx, change.UserID)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	err = qtx.UpdateUserEmail(h.Ctx, repository.UpdateUserEmailParams{
+		ID:             change.UserID,
+		Email:          change.NewEmail,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) CancelEmailChange(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return NewResponse(c, "failed", nil, "token is required", http.StatusBadRequest)
+	}
+
+	cancelled, err := h.Repo.CancelEmailChange(h.Ctx, HashApiKey(token))
+	if err != nil {
+		return DBError(err, "email change")
+	}
+	if cancelled == 0 {
+		return NewResponse(c, "failed", nil, "invalid token or the change was already confirmed", http.StatusBadRequest)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
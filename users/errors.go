@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+)
+
+// error codes are part of the api, clients may switch on them so existing
+// values must not change
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeValidationFailed = "validation_failed"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+
+	CodeUsernameTaken   = "username_taken"
+	CodeEmailTaken      = "email_taken"
+	CodeRoleNameTaken   = "role_name_taken"
+	CodePermissionTaken = "permission_taken"
+	CodeInvitationOpen  = "invitation_open"
+	CodeInvalidRef      = "invalid_reference"
+	CodeSlugTaken       = "slug_taken"
+	CodeGroupNameTaken  = "group_name_taken"
+
+	CodeRegistrationClosed = "registration_closed"
+	CodeEmailDomainDenied  = "email_domain_not_allowed"
+	CodeApprovalPending    = "approval_pending"
+	CodeAccountInactive    = "account_inactive"
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgInvalidText         = "22P02"
+)
+
+// DomainError is a failure the client can act on. Code is meant for programs
+// and Message for people, Err keeps the cause for the logs and is never sent.
+type DomainError struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  ValidationErrors
+	Err     error
+}
+
+func (e *DomainError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind, so errors.Is(err, ErrConflict) holds
+// for an email_taken conflict too
+func (e *DomainError) Is(target error) bool {
+	t, ok := target.(*DomainError)
+	return ok && t.Status == e.Status
+}
+
+// kinds of domain errors, for use with errors.Is
+var (
+	ErrNotFound   = &DomainError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "not found"}
+	ErrConflict   = &DomainError{Status: http.StatusConflict, Code: CodeConflict, Message: "conflict"}
+	ErrForbidden  = &DomainError{Status: http.StatusForbidden, Code: CodeForbidden, Message: "forbidden"}
+	ErrValidation = &DomainError{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: "validation failed"}
+)
+
+func NotFoundError(message string) *DomainError {
+	return &DomainError{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+func ConflictError(code, message string) *DomainError {
+	return &DomainError{Status: http.StatusConflict, Code: code, Message: message}
+}
+
+func ForbiddenError(message string) *DomainError {
+	return &DomainError{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+func ValidationError(fields ValidationErrors) *DomainError {
+	return &DomainError{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: "validation failed", Fields: fields}
+}
+
+// InternalError hides err from the client, it is only logged
+func InternalError(err error) *DomainError {
+	return &DomainError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal server error", Err: err}
+}
+
+// uniqueConstraints names the unique indexes clients can run into
+var uniqueConstraints = map[string]struct{ code, field, message string }{
+	"users_username_live_key":    {CodeUsernameTaken, "username", "username is already taken"},
+	"users_email_live_key":       {CodeEmailTaken, "email", "email is already taken"},
+	"roles_role_name_live_key":   {CodeRoleNameTaken, "role_name", "role name is already taken"},
+	"permissions_name_live_key":  {CodePermissionTaken, "name", "permission already exists"},
+	"invitations_open_email_key": {CodeInvitationOpen, "email", "an open invitation for this email already exists"},
+	"organizations_slug_key":     {CodeSlugTaken, "slug", "slug is already taken"},
+	"groups_name_key":            {CodeGroupNameTaken, "name", "group name is already taken"},
+}
+
+// DBError translates a repository error into a domain error, resource names
+// the record for not found and conflict messages, e.g. "user". Errors the
+// client cannot fix become internal errors.
+func DBError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &DomainError{Status: http.StatusNotFound, Code: CodeNotFound, Message: resource + " not found", Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return InternalError(err)
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if constraint, ok := uniqueConstraints[pgErr.ConstraintName]; ok {
+			return &DomainError{
+				Status:  http.StatusConflict,
+				Code:    constraint.code,
+				Message: constraint.message,
+				Fields:  ValidationErrors{{Field: constraint.field, Rule: "unique", Message: "is already taken"}},
+				Err:     err,
+			}
+		}
+		return &DomainError{Status: http.StatusConflict, Code: CodeConflict, Message: resource + " already exists", Err: err}
+	case pgForeignKeyViolation:
+		return &DomainError{Status: http.StatusConflict, Code: CodeInvalidRef, Message: resource + " references a record that does not exist or is still referenced", Err: err}
+	case pgNotNullViolation:
+		field := pgErr.ColumnName
+		return &DomainError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeValidationFailed,
+			Message: "validation failed",
+			Fields:  ValidationErrors{{Field: field, Rule: "required", Message: "is required"}},
+			Err:     err,
+		}
+	case pgCheckViolation, pgStringTooLong:
+		return &DomainError{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Message: resource + " has an invalid value", Err: err}
+	case pgInvalidText:
+		return &DomainError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: "malformed value", Err: err}
+	}
+	return InternalError(err)
+}
+
+// codeForStatus is the error code of responses that carry no domain error
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusInternalServerError:
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// toDomainError classifies whatever a handler or middleware returned
+func toDomainError(err error) *DomainError {
+	var domainErr *DomainError
+	var validationErrors ValidationErrors
+	var policyErr *PasswordPolicyError
+	var httpErr *echo.HTTPError
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &domainErr):
+		return domainErr
+	case errors.As(err, &validationErrors):
+		return ValidationError(validationErrors)
+	case errors.As(err, &policyErr):
+		return ValidationError(policyErr.Fields())
+	case errors.As(err, &httpErr):
+		message, ok := httpErr.Message.(string)
+		if !ok {
+			message = fmt.Sprint(httpErr.Message)
+		}
+		if httpErr.Code >= http.StatusInternalServerError {
+			message = http.StatusText(httpErr.Code)
+		}
+		return &DomainError{Status: httpErr.Code, Code: codeForStatus(httpErr.Code), Message: message, Err: httpErr.Internal}
+	case errors.Is(err, pgx.ErrNoRows), errors.As(err, &pgErr):
+		return DBError(err, "record").(*DomainError)
+	}
+	return InternalError(err)
+}
+
+// NewHTTPErrorHandler renders errors returned by handlers in the Response
+// envelope, server errors are logged with their cause
+func NewHTTPErrorHandler(logger *slog.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		domainErr := toDomainError(err)
+		if domainErr.Status >= http.StatusInternalServerError {
+			logger.Error("request failed", "method", c.Request().Method, "path", c.Path(), "code", domainErr.Code, "error", err)
+		} else if domainErr.Err != nil {
+			logger.Debug("request rejected", "method", c.Request().Method, "path", c.Path(), "code", domainErr.Code, "error", err)
+		}
+
+		res := &Response{
+			Message:   "failed",
+			Err:       domainErr.Message,
+			ErrorCode: domainErr.Code,
+			Errors:    domainErr.Fields,
+			Code:      domainErr.Status,
+		}
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(res.Code)
+		} else {
+			err = c.JSON(res.Code, res)
+		}
+		if err != nil {
+			logger.Error("failed to write error response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// event types, like error codes they are part of the api and must not change
+const (
+	EventUserRegistered  = "user.registered"
+	EventUserVerified    = "user.verified"
+	EventUserActivated   = "user.activated"
+	EventUserDeactivated = "user.deactivated"
+	EventUserDeleted     = "user.deleted"
+	EventUserRoleChanged = "user.role_changed"
+	EventRoleCreated     = "role.created"
+	EventRoleUpdated     = "role.updated"
+	EventRoleDeleted     = "role.deleted"
+)
+
+// how a user came to be registered
+const (
+	RegistrationSourceSelf       = "self"
+	RegistrationSourceInvitation = "invitation"
+	RegistrationSourceAdmin      = "admin"
+	RegistrationSourceImport     = "import"
+)
+
+// Event is the envelope every event is published in. Subject names the
+// record it is about, e.g. "users/42", and orders events per record.
+// OrganizationID is the tenant the record belongs to.
+type Event struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Version        int             `json:"version"`
+	Subject        string          `json:"subject"`
+	OrganizationID int32           `json:"organization_id"`
+	OccurredAt     time.Time       `json:"occurred_at"`
+	ActorID        int64           `json:"actor_id,omitempty"`
+	Data           json.RawMessage `json:"data"`
+}
+
+// UserRegisteredData carries no personal data, outbox rows and webhook
+// deliveries outlive an erased user. Consumers look the user up by id.
+type UserRegisteredData struct {
+	UserID   int32  `json:"user_id"`
+	Role     int64  `json:"role"`
+	IsActive bool   `json:"is_active"`
+	Source   string `json:"source"`
+}
+
+// UserEventData is the payload of events that need nothing but the user
+type UserEventData struct {
+	UserID int32 `json:"user_id"`
+}
+
+type UserRoleChangedData struct {
+	UserID       int32 `json:"user_id"`
+	PreviousRole int64 `json:"previous_role"`
+	Role         int64 `json:"role"`
+}
+
+type RoleEventData struct {
+	RoleID      int32    `json:"role_id"`
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+}
+
+// EventType describes the current version of an event. Changing a payload
+// in a way consumers can notice means bumping the version and adding the
+// new schema, the schemas of earlier versions stay as they are.
+type EventType struct {
+	Name        string
+	Version     int
+	Description string
+	Data        any
+}
+
+// version 2 of every event added organization_id to the envelope, version 3
+// of user.registered dropped the username and the email
+var EventTypes = []EventType{
+	{EventUserRegistered, 3, "A user account was created, by registration, invitation, an admin or an import", UserRegisteredData{}},
+	{EventUserVerified, 2, "A user verified their email", UserEventData{}},
+	{EventUserActivated, 2, "A user was activated, or approved after registering", UserEventData{}},
+	{EventUserDeactivated, 2, "A user was deactivated and can no longer log in", UserEventData{}},
+	{EventUserDeleted, 2, "A user was deleted, rejected or erased", UserEventData{}},
+	{EventUserRoleChanged, 2, "A user was given another role", UserRoleChangedData{}},
+	{EventRoleCreated, 2, "A role was created", RoleEventData{}},
+	{EventRoleUpdated, 2, "A role was renamed or its permissions changed", RoleEventData{}},
+	{EventRoleDeleted, 2, "A role was deleted", RoleEventData{}},
+}
+
+func eventType(name string) (EventType, bool) {
+	for _, t := range EventTypes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return EventType{}, false
+}
+
+func userSubject(id int32) string {
+	return "users/" + strconv.Itoa(int(id))
+}
+
+func roleSubject(id int32) string {
+	return "roles/" + strconv.Itoa(int(id))
+}
+
+// NewEvent builds the envelope of the current version of an event
+func NewEvent(name, subject string, organizationID int32, actorID int64, data any) (Event, error) {
+	t, ok := eventType(name)
+	if !ok {
+		return Event{}, fmt.Errorf("unknown event type %s", name)
+	}
+	if reflect.TypeOf(data) != reflect.TypeOf(t.Data) {
+		return Event{}, fmt.Errorf("event %s carries %T, not %T", name, data, t.Data)
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:             uuid.NewString(),
+		Type:           t.Name,
+		Version:        t.Version,
+		Subject:        subject,
+		OrganizationID: organizationID,
+		OccurredAt:     time.Now().UTC(),
+		ActorID:        actorID,
+		Data:           payload,
+	}, nil
+}
+
+// recordEvent records an event about a record of the tenant of the request
+func recordEvent(ctx context.Context, repo *repository.Queries, c echo.Context, name, subject string, data any) error {
+	return recordOrganizationEvent(ctx, repo, c, tenantID(c), name, subject, data)
+}
+
+// recordOrganizationEvent stores an event in the outbox and queues a delivery
+// to every webhook of the organization subscribed to it. repo has to be bound
+// to the transaction of the change so the event goes out only if it commits.
+func recordOrganizationEvent(ctx context.Context, repo *repository.Queries, c echo.Context, organizationID int32, name, subject string, data any) error {
+	actorID, _ := c.Get("userID").(int64)
+	event, err := NewEvent(name, subject, organizationID, actorID, data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	eventID := pgtype.UUID{Bytes: uuid.MustParse(event.ID), Valid: true}
+	err = repo.CreateOutboxEvent(ctx, repository.CreateOutboxEventParams{
+		EventID: eventID,
+		Type:    event.Type,
+		Version: int32(event.Version),
+		Payload: payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.CreateWebhookDeliveries(ctx, repository.CreateWebhookDeliveriesParams{
+		EventID:        eventID,
+		EventType:      event.Type,
+		Payload:        payload,
+		OrganizationID: organizationID,
+	})
+	return err
+}
+
+func recordUserRegistered(ctx context.Context, repo *repository.Queries, c echo.Context, user repository.User, source string) error {
+	return recordOrganizationEvent(ctx, repo, c, user.OrganizationID, EventUserRegistered, userSubject(user.ID), UserRegisteredData{
+		UserID:   user.ID,
+		Role:     user.Role,
+		IsActive: user.IsActive.Bool,
+		Source:   source,
+	})
+}
+
+// recordUserChanges records the events of an update that gave user another
+// role or (de)activated them
+func recordUserChanges(ctx context.Context, repo *repository.Queries, c echo.Context, user repository.User, role int64, isActive bool) error {
+	if role != user.Role {
+		err := recordOrganizationEvent(ctx, repo, c, user.OrganizationID, EventUserRoleChanged, userSubject(user.ID), UserRoleChangedData{
+			UserID:       user.ID,
+			PreviousRole: user.Role,
+			Role:         role,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if isActive == user.IsActive.Bool {
+		return nil
+	}
+	event := EventUserDeactivated
+	if isActive {
+		event = EventUserActivated
+	}
+	return recordOrganizationEvent(ctx, repo, c, user.OrganizationID, event, userSubject(user.ID), UserEventData{UserID: user.ID})
+}
+
+func recordRoleEvent(ctx context.Context, repo *repository.Queries, c echo.Context, name string, role repository.Role) error {
+	return recordOrganizationEvent(ctx, repo, c, role.OrganizationID, name, roleSubject(role.ID), RoleEventData{
+		RoleID:      role.ID,
+		RoleName:    role.RoleName,
+		Permissions: rolePermissions(role.Permissions),
+	})
+}
+
+// EventSchema is the JSON schema of the current version of an event
+func EventSchema(t EventType) map[string]any {
+	dataType := reflect.TypeOf(t.Data)
+	data := newSchemaBuilder().structSchema(dataType)
+
+	// payloads carry every field, an empty one is zero rather than missing
+	required := make([]string, 0, dataType.NumField())
+	for i := 0; i < dataType.NumField(); i++ {
+		name, _, _ := strings.Cut(dataType.Field(i).Tag.Get("json"), ",")
+		required = append(required, name)
+	}
+	data["required"] = required
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("urn:users:events:%s:v%d", t.Name, t.Version),
+		"title":       t.Name,
+		"description": t.Description,
+		"type":        "object",
+		"properties": map[string]any{
+			"id":              map[string]any{"type": "string", "format": "uuid"},
+			"type":            map[string]any{"const": t.Name},
+			"version":         map[string]any{"const": t.Version},
+			"subject":         map[string]any{"type": "string"},
+			"organization_id": map[string]any{"type": "integer", "format": "int32"},
+			"occurred_at":     map[string]any{"type": "string", "format": "date-time"},
+			"actor_id":        map[string]any{"type": "integer", "format": "int64"},
+			"data":            data,
+		},
+		"required": []string{"id", "type", "version", "subject", "organization_id", "occurred_at", "data"},
+	}
+}
+
+// eventSchemaFiles holds the schema of every version of every event, named
+// <type>.v<version>.json. The schema of a current version is checked against
+// its payload by the tests.
+//
+//go:embed schemas/events/*.json
+var eventSchemaFiles embed.FS
+
+// ListEventSchemas serves every version of every event schema, consumers
+// still reading older versions can keep validating against them
+func (h *AuthHandler) ListEventSchemas(c echo.Context) error {
+	files, err := fs.Glob(eventSchemaFiles, "schemas/events/*.json")
+	if err != nil {
+		return InternalError(err)
+	}
+
+	schemas := make([]EventSchemaDTO, 0)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".json")
+		i := strings.LastIndex(name, ".v")
+		version, err := strconv.Atoi(name[i+2:])
+		if i < 0 || err != nil {
+			return InternalError(fmt.Errorf("event schema %s is not named <type>.v<version>.json", file))
+		}
+
+		data, err := eventSchemaFiles.ReadFile(file)
+		if err != nil {
+			return InternalError(err)
+		}
+		schema := make(map[string]any)
+		if err = json.Unmarshal(data, &schema); err != nil {
+			return InternalError(fmt.Errorf("event schema %s: %w", file, err))
+		}
+
+		schemas = append(schemas, EventSchemaDTO{Type: name[:i], Version: version, Schema: schema})
+	}
+
+	return NewResponse(c, "success", schemas, "", http.StatusOK)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TestEventSchemas fails when the payload of an event changed without its
+// version being bumped. UPDATE_EVENT_SCHEMAS=1 writes the schema files of the
+// current versions, earlier versions must stay as they are.
+func TestEventSchemas(t *testing.T) {
+	for _, eventType := range EventTypes {
+		file := filepath.Join("schemas", "events", fmt.Sprintf("%s.v%d.json", eventType.Name, eventType.Version))
+		want, err := json.MarshalIndent(EventSchema(eventType), "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if os.Getenv("UPDATE_EVENT_SCHEMAS") != "" {
+			if err = os.WriteFile(file, append(want, '\n'), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s v%d has no schema file: %v", eventType.Name, eventType.Version, err)
+			continue
+		}
+		var got any
+		if err = json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		normalized, _ := json.MarshalIndent(got, "", "  ")
+		if string(normalized) != string(want) {
+			t.Errorf("the payload of %s changed, bump its version and add %s.v%d.json", eventType.Name, eventType.Name, eventType.Version+1)
+		}
+	}
+}
+
+func TestListEventSchemas(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/events/schemas", nil)
+	rec := httptest.NewRecorder()
+	s.Echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var res struct {
+		Data []EventSchemaDTO `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Data) < len(EventTypes) {
+		t.Fatalf("got %d schemas, want at least %d", len(res.Data), len(EventTypes))
+	}
+}
+
+func TestNewEventRejectsWrongPayload(t *testing.T) {
+	if _, err := NewEvent(EventUserVerified, userSubject(1), DefaultOrganizationID, 0, RoleEventData{}); err == nil {
+		t.Fatal("want an error for a payload of another event")
+	}
+	if _, err := NewEvent("user.unknown", userSubject(1), DefaultOrganizationID, 0, UserEventData{}); err == nil {
+		t.Fatal("want an error for an unknown event")
+	}
+}
+
+func testEvent(t *testing.T) Event {
+	t.Helper()
+	event, err := NewEvent(EventUserVerified, userSubject(42), DefaultOrganizationID, 1, UserEventData{UserID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	got := make([]string, 0)
+	bus.Subscribe(EventUserVerified, func(ctx context.Context, event Event) error {
+		got = append(got, "typed")
+		return nil
+	})
+	bus.Subscribe(EventUserDeleted, func(ctx context.Context, event Event) error {
+		got = append(got, "other")
+		return nil
+	})
+	bus.Subscribe("*", func(ctx context.Context, event Event) error {
+		got = append(got, "wildcard")
+		return errors.New("consumer down")
+	})
+
+	err := bus.Publish(context.Background(), testEvent(t))
+	if err == nil {
+		t.Fatal("want the failing handler's error")
+	}
+	if fmt.Sprint(got) != "[typed wildcard]" {
+		t.Fatalf("handlers run = %v", got)
+	}
+}
+
+func TestWebhookEventPublisher(t *testing.T) {
+	var received Event
+	status := http.StatusNoContent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		if r.Header.Get("X-Event-Type") != EventUserVerified {
+			t.Errorf("X-Event-Type = %q", r.Header.Get("X-Event-Type"))
+		}
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	publisher := &WebhookEventPublisher{URL: receiver.URL, Client: receiver.Client()}
+	event := testEvent(t)
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if received.ID != event.ID || string(received.Data) != `{"user_id":42}` {
+		t.Fatalf("received %+v", received)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := publisher.Publish(context.Background(), event); err == nil {
+		t.Fatal("want an error for a 503")
+	}
+}
+
+// TestNatsEventPublisher runs against a local server, e.g.
+// docker run -p 4222:4222 nats, with NATS_URL=nats://localhost:4222
+func TestNatsEventPublisher(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL is not set")
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sub, err := conn.SubscribeSync("test.events.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &NatsEventPublisher{Conn: conn, SubjectPrefix: "test.events"}
+	event := testEvent(t)
+	if err = publisher.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "test.events."+EventUserVerified || msg.Header.Get(nats.MsgIdHdr) != event.ID {
+		t.Fatalf("received %s %v", msg.Subject, msg.Header)
+	}
+}
+
+// TestKafkaEventPublisher runs against a local REST proxy, e.g. Redpanda's
+// on port 8082 with KAFKA_REST_URL=http://localhost:8082
+func TestKafkaEventPublisher(t *testing.T) {
+	url := os.Getenv("KAFKA_REST_URL")
+	if url == "" {
+		t.Skip("KAFKA_REST_URL is not set")
+	}
+
+	publisher := &KafkaEventPublisher{URL: url, Topic: getEnv("KAFKA_TOPIC", "users.events"), Client: http.DefaultClient}
+	if err := publisher.Publish(context.Background(), testEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+}
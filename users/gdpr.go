@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// exportUser collects everything this service stores about a user. Loyalty
+// transactions live in the loyalty service and are not part of this export.
+func exportUser(ctx context.Context, repo *repository.Queries, userID, organizationID int32) (*UserExportDTO, error) {
+	user, err := repo.GetUserIncludingDeleted(ctx, repository.GetUserIncludingDeletedParams{ID: userID, OrganizationID: organizationID})
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExportDTO{
+		ExportedAt: time.Now().UTC(),
+		User: UserGetDTO{
+			ID:              user.ID,
+			OrganizationID:  user.OrganizationID,
+			Username:        user.Username,
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			PhoneNumber:     user.PhoneNumber,
+			PhoneVerified:   user.PhoneVerified,
+			SmsTwoFactor:    user.SmsTwoFactor,
+			IsActive:        user.IsActive,
+			IsVerified:      user.IsVerified,
+			ApprovalPending: user.ApprovalPending,
+			Role:            user.Role,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+			DeletedAt:       user.DeletedAt,
+		},
+		PasswordChangedAt: user.PasswordChangedAt,
+		AnonymizedAt:      user.AnonymizedAt,
+		PasswordHistory:   make([]pgtype.Timestamp, 0),
+	}
+
+	if role, err := repo.GetRole(ctx, repository.GetRoleParams{ID: int32(user.Role), OrganizationID: user.OrganizationID}); err == nil {
+		export.Role = &RoleExportDTO{ID: role.ID, Name: role.RoleName, Permissions: role.Permissions}
+	}
+
+	sessions, err := repo.ListAllUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.Sessions = newSessionsDTO(sessions, 0)
+
+	keys, err := repo.ListAllUserApiKeys(ctx, pgtype.Int4{Int32: user.ID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	export.ApiKeys = make([]ApiKeyDTO, 0)
+	for _, key := range keys {
+		export.ApiKeys = append(export.ApiKeys, newApiKeyDTO(key))
+	}
+
+	// hashes are never exported, only when each password was set
+	history, err := repo.ListAllPasswordHistory(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range history {
+		export.PasswordHistory = append(export.PasswordHistory, entry.CreatedAt)
+	}
+
+	// invitations are kept by address, not by user
+	invitations, err := repo.ListEmailInvitations(ctx, repository.ListEmailInvitationsParams{
+		OrganizationID: user.OrganizationID,
+		Email:          user.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	export.Invitations = make([]InvitationDTO, 0)
+	for _, invitation := range invitations {
+		export.Invitations = append(export.Invitations, newInvitationDTO(invitation))
+	}
+
+	entries, err := repo.ListUserAuditEntries(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export.AuditEntries = newAuditEntriesDTO(entries)
+
+	return export, nil
+}
+
+func (h *AuthHandler) sendExport(c echo.Context, userID, organizationID int32) error {
+	export, err := exportUser(h.Ctx, h.Repo, userID, organizationID)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err := recordAudit(h.Ctx, h.Repo, c, AuditUserExport, userID, nil); err != nil {
+		return DBError(err, "audit entry")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="user-%d-export.json"`, userID))
+	return NewResponse(c, "success", export, "", http.StatusOK)
+}
+
+// data subject handlers
+
+func (h *AuthHandler) ExportMyData(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	if userID == 0 {
+		return NewResponse(c, "failed", nil, "only users can export their data", http.StatusBadRequest)
+	}
+
+	// members of the tenant export the account of their own organization
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return DBError(err, "user")
+	}
+	return h.sendExport(c, user.ID, user.OrganizationID)
+}
+
+func (h *AuthHandler) ExportUser(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	return h.sendExport(c, int32(id), tenantID(c))
+}
+
+// EraseUser scrubs the PII columns of a user in place. The row is kept so
+// anything referencing the id stays valid, sessions, password history, email
+// changes, sms codes and the invitations sent to the address are removed, api
+// keys are revoked and the audit trail is left untouched.
+func (h *AuthHandler) EraseUser(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	// the address is needed after anonymizing to find its invitations
+	user, err := qtx.GetUserIncludingDeleted(h.Ctx, repository.GetUserIncludingDeletedParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NewResponse(c, "failed", nil, "user not found or already erased", http.StatusNotFound)
+	}
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	erased, err := qtx.AnonymizeUser(h.Ctx, repository.AnonymizeUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "user")
+	}
+	if erased == 0 {
+		return NewResponse(c, "failed", nil, "user not found or already erased", http.StatusNotFound)
+	}
+
+	if err := qtx.DeleteUserSessions(h.Ctx, int32(id)); err != nil {
+		return DBError(err, "session")
+	}
+
+	if err := qtx.DeleteUserPasswordHistory(h.Ctx, int32(id)); err != nil {
+		return DBError(err, "password history")
+	}
+
+	if err := qtx.DeleteUserPasswordResets(h.Ctx, int32(id)); err != nil {
+		return DBError(err, "password reset")
+	}
+
+	if err := qtx.DeleteUserEmailChanges(h.Ctx, int32(id)); err != nil {
+		return DBError(err, "email change")
+	}
+
+	if err := qtx.DeleteUserPhoneOtps(h.Ctx, int32(id)); err != nil {
+		return DBError(err, "phone code")
+	}
+
+	if err := qtx.RevokeUserApiKeys(h.Ctx, pgtype.Int4{Int32: int32(id), Valid: true}); err != nil {
+		return DBError(err, "api key")
+	}
+
+	err = qtx.DeleteEmailInvitations(h.Ctx, repository.DeleteEmailInvitationsParams{
+		OrganizationID: user.OrganizationID,
+		Email:          user.Email,
+	})
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+
+	if err := recordAudit(h.Ctx, qtx, c, AuditUserErase, int32(id), nil); err != nil {
+		return DBError(err, "audit entry")
+	}
+
+	if err := recordEvent(h.Ctx, qtx, c, EventUserDeleted, userSubject(int32(id)), UserEventData{UserID: int32(id)}); err != nil {
+		return InternalError(err)
+	}
+
+	if err := tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"users/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newGroupDTO(group repository.Group) GroupGetDTO {
+	return GroupGetDTO{
+		ID:        group.ID,
+		Name:      group.Name,
+		CreatedAt: group.CreatedAt,
+		UpdatedAt: group.UpdatedAt,
+	}
+}
+
+func newGroupsDTO(groups []repository.Group) []GroupGetDTO {
+	groupsDTO := make([]GroupGetDTO, 0)
+	for _, group := range groups {
+		groupsDTO = append(groupsDTO, newGroupDTO(group))
+	}
+	return groupsDTO
+}
+
+// tenantGroup loads the group named by the :id parameter, groups of other
+// organizations are not found
+func (h *AuthHandler) tenantGroup(c echo.Context) (repository.Group, error) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	group, err := h.Repo.GetGroup(h.Ctx, repository.GetGroupParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return group, DBError(err, "group")
+	}
+	return group, nil
+}
+
+// groups handlers
+
+func (h *AuthHandler) ListGroups(c echo.Context) error {
+	groups, err := h.Repo.ListGroups(h.Ctx, tenantID(c))
+	if err != nil {
+		return DBError(err, "group")
+	}
+
+	return NewResponse(c, "success", newGroupsDTO(groups), "", http.StatusOK)
+}
+
+// GetGroup returns the group with the roles it grants its members
+func (h *AuthHandler) GetGroup(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	roles, err := h.Repo.ListGroupRoles(h.Ctx, group.ID)
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	groupDTO := newGroupDTO(group)
+	groupDTO.Roles = roles
+	return NewResponse(c, "success", groupDTO, "", http.StatusOK)
+}
+
+func (h *AuthHandler) CreateGroup(c echo.Context) error {
+	data := new(GroupDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	group, err := h.Repo.CreateGroup(h.Ctx, repository.CreateGroupParams{
+		OrganizationID: tenantID(c),
+		Name:           data.Name,
+	})
+	if err != nil {
+		return DBError(err, "group")
+	}
+
+	return NewResponse(c, "success", newGroupDTO(group), "", http.StatusCreated)
+}
+
+func (h *AuthHandler) RenameGroup(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	data := new(GroupDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	group, err := h.Repo.RenameGroup(h.Ctx, repository.RenameGroupParams{
+		ID:             int32(id),
+		Name:           data.Name,
+		OrganizationID: tenantID(c),
+	})
+	if err != nil {
+		return DBError(err, "group")
+	}
+
+	return NewResponse(c, "success", newGroupDTO(group), "", http.StatusOK)
+}
+
+// DeleteGroup removes the group with its memberships and grants, members keep
+// their own role
+func (h *AuthHandler) DeleteGroup(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	deleted, err := h.Repo.DeleteGroup(h.Ctx, repository.DeleteGroupParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "group")
+	}
+	if deleted == 0 {
+		return NotFoundError("group not found")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) ListGroupMembers(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	members, err := h.Repo.ListGroupMembers(h.Ctx, group.ID)
+	if err != nil {
+		return DBError(err, "group member")
+	}
+
+	membersDTO := make([]GroupMemberGetDTO, 0)
+	for _, member := range members {
+		membersDTO = append(membersDTO, GroupMemberGetDTO{
+			GroupID:   member.GroupID,
+			UserID:    member.UserID,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+
+	return NewResponse(c, "success", membersDTO, "", http.StatusOK)
+}
+
+// AddGroupMember takes users of the organization and members from other
+// organizations, adding a member twice changes nothing
+func (h *AuthHandler) AddGroupMember(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	user, err := tenantUser(h.Ctx, h.Repo, int64(userID), group.OrganizationID)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	err = h.Repo.AddGroupMember(h.Ctx, repository.AddGroupMemberParams{GroupID: group.ID, UserID: user.ID})
+	if err != nil {
+		return DBError(err, "group member")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) RemoveGroupMember(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	removed, err := h.Repo.RemoveGroupMember(h.Ctx, repository.RemoveGroupMemberParams{GroupID: group.ID, UserID: int32(userID)})
+	if err != nil {
+		return DBError(err, "group member")
+	}
+	if removed == 0 {
+		return NotFoundError("group member not found")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+// GrantGroupRole gives every member of the group the permissions of a role
+// of the organization, on top of their own role
+func (h *AuthHandler) GrantGroupRole(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	roleID, _ := strconv.Atoi(c.Param("roleId"))
+	role, err := h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(roleID), OrganizationID: group.OrganizationID})
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = h.Repo.GrantGroupRole(h.Ctx, repository.GrantGroupRoleParams{GroupID: group.ID, RoleID: role.ID}); err != nil {
+		return DBError(err, "group role")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) RevokeGroupRole(c echo.Context) error {
+	group, err := h.tenantGroup(c)
+	if err != nil {
+		return err
+	}
+
+	roleID, _ := strconv.Atoi(c.Param("roleId"))
+	revoked, err := h.Repo.RevokeGroupRole(h.Ctx, repository.RevokeGroupRoleParams{GroupID: group.ID, RoleID: int32(roleID)})
+	if err != nil {
+		return DBError(err, "group role")
+	}
+	if revoked == 0 {
+		return NotFoundError("role is not granted to the group")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) ListUserGroups(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := tenantUser(h.Ctx, h.Repo, int64(id), tenantID(c))
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	return h.sendUserGroups(c, user.ID)
+}
+
+func (h *AuthHandler) ListMyGroups(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	return h.sendUserGroups(c, int32(userID))
+}
+
+func (h *AuthHandler) sendUserGroups(c echo.Context, userID int32) error {
+	groups, err := h.Repo.ListUserGroups(h.Ctx, repository.ListUserGroupsParams{
+		OrganizationID: tenantID(c),
+		UserID:         userID,
+	})
+	if err != nil {
+		return DBError(err, "group")
+	}
+
+	return NewResponse(c, "success", newGroupsDTO(groups), "", http.StatusOK)
+}
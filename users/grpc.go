@@ -0,0 +1,346 @@
+package main
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=users --go-grpc_out=. --go-grpc_opt=module=users users/v1/auth.proto
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"users/authpb"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcMethodPermissions is what a caller needs for each method, methods
+// missing here are refused
+var grpcMethodPermissions = map[string]string{
+	authpb.AuthService_Authenticate_FullMethodName:    AuthIntrospectPermission,
+	authpb.AuthService_IntrospectToken_FullMethodName: AuthIntrospectPermission,
+	authpb.AuthService_CheckPermission_FullMethodName: AuthIntrospectPermission,
+	authpb.AuthService_GetUser_FullMethodName:         UsersReadPermission,
+	authpb.AuthService_ListUserRoles_FullMethodName:   RolesReadPermission,
+}
+
+// grpcPrincipalKey holds the authenticated caller in the context of a call
+type grpcPrincipalKey struct{}
+
+// GrpcServer serves AuthService to internal services, it shares the
+// repository and the token and api key checks with AuthHandler
+type GrpcServer struct {
+	authpb.UnimplementedAuthServiceServer
+	Repo   *repository.Queries
+	Cfg    *Config
+	Logger *slog.Logger
+}
+
+func NewGrpcServer(auth *GrpcServer) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		auth.recoverInterceptor,
+		auth.loggingInterceptor,
+		auth.authInterceptor,
+	))
+	authpb.RegisterAuthServiceServer(server, auth)
+	return server
+}
+
+// authInterceptor authenticates the caller like the http Authenticate
+// middleware, with an api key in the x-api-key metadata or a bearer token
+func (s *GrpcServer) authInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	permission, ok := grpcMethodPermissions[info.FullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "invalid permissions")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var principal *authpb.Principal
+	var err error
+	if keys := md.Get(strings.ToLower(ApiKeyHeader)); len(keys) > 0 {
+		principal, err = s.authenticateApiKey(ctx, keys[0])
+	} else if auth := md.Get("authorization"); len(auth) > 0 && strings.HasPrefix(auth[0], "Bearer ") {
+		principal, err = s.authenticateToken(ctx, strings.TrimPrefix(auth[0], "Bearer "))
+	} else {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if !HasPermission(principal.Permissions, permission) {
+		return nil, status.Error(codes.PermissionDenied, "invalid permissions")
+	}
+
+	return handler(context.WithValue(ctx, grpcPrincipalKey{}, principal), req)
+}
+
+func (s *GrpcServer) loggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", info.FullMethod),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	switch code {
+	case codes.OK:
+		s.Logger.LogAttrs(ctx, slog.LevelInfo, "GRPC", attrs...)
+	case codes.Internal, codes.Unknown:
+		s.Logger.LogAttrs(ctx, slog.LevelError, "GRPC_ERROR", append(attrs, slog.String("err", err.Error()))...)
+	default:
+		s.Logger.LogAttrs(ctx, slog.LevelWarn, "GRPC_ERROR", append(attrs, slog.String("err", err.Error()))...)
+	}
+	return res, err
+}
+
+// recoverInterceptor turns a panic into an internal error, like echo's Recover
+func (s *GrpcServer) recoverInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Logger.Error("grpc handler panicked", "method", info.FullMethod, "panic", r)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// grpcError maps errors to grpc statuses the way the http error handler maps
+// them to status codes, internal causes are logged but not sent
+func (s *GrpcServer) grpcError(ctx context.Context, err error) error {
+	domainErr := toDomainError(err)
+
+	code := codes.Internal
+	switch domainErr.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+
+	if code == codes.Internal {
+		s.Logger.ErrorContext(ctx, "grpc request failed", "code", domainErr.Code, "error", err)
+	}
+	return status.Error(code, domainErr.Message)
+}
+
+func (s *GrpcServer) authenticateToken(ctx context.Context, token string) (*authpb.Principal, error) {
+	claims, err := AuthenticateToken(ctx, s.Cfg, s.Repo, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authpb.Principal{
+		UserId:         claims.UserID,
+		Role:           claims.Role,
+		Permissions:    claims.Permissions,
+		SessionId:      claims.SessionID,
+		OrganizationId: claims.OrganizationID,
+	}, nil
+}
+
+func (s *GrpcServer) authenticateApiKey(ctx context.Context, key string) (*authpb.Principal, error) {
+	apiKey, err := AuthenticateApiKey(ctx, s.Repo, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authpb.Principal{
+		UserId:         apiKey.UserID,
+		Role:           apiKey.Role,
+		Permissions:    apiKey.Permissions,
+		ApiKeyId:       apiKey.KeyID,
+		ServiceAccount: apiKey.ServiceAccount,
+		OrganizationId: apiKey.OrganizationID,
+	}, nil
+}
+
+func (s *GrpcServer) Authenticate(ctx context.Context, req *authpb.AuthenticateRequest) (*authpb.Principal, error) {
+	var principal *authpb.Principal
+	var err error
+	switch credential := req.Credential.(type) {
+	case *authpb.AuthenticateRequest_AccessToken:
+		principal, err = s.authenticateToken(ctx, credential.AccessToken)
+	case *authpb.AuthenticateRequest_ApiKey:
+		principal, err = s.authenticateApiKey(ctx, credential.ApiKey)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "access_token or api_key is required")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	// credentials of another tenant are as good as unknown to the caller
+	if _, err := requestTenant(ctx, principal.OrganizationId); err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+
+	return principal, nil
+}
+
+// IntrospectToken follows RFC 7662, a token that does not verify or whose
+// session ended is reported inactive without details. The session is not
+// touched since introspection is no use of the token by its owner.
+func (s *GrpcServer) IntrospectToken(ctx context.Context, req *authpb.IntrospectTokenRequest) (*authpb.IntrospectTokenResponse, error) {
+	tokenType := TokenType(req.TokenType)
+	if tokenType == "" {
+		tokenType = AccessToken
+	}
+
+	claims, err := ParseToken(s.Cfg, req.Token, tokenType)
+	if err != nil {
+		return &authpb.IntrospectTokenResponse{Active: false}, nil
+	}
+
+	if _, err := requestTenant(ctx, claims.OrganizationID); err != nil {
+		return &authpb.IntrospectTokenResponse{Active: false}, nil
+	}
+
+	if tokenType == AccessToken || tokenType == RefreshToken {
+		if _, err := s.Repo.GetActiveSession(ctx, claims.SessionID); err != nil {
+			return &authpb.IntrospectTokenResponse{Active: false}, nil
+		}
+	}
+
+	res := &authpb.IntrospectTokenResponse{
+		Active:         true,
+		TokenType:      string(claims.TokenType),
+		UserId:         claims.UserID,
+		Role:           claims.Role,
+		Permissions:    claims.Permissions,
+		SessionId:      claims.SessionID,
+		Issuer:         claims.Issuer,
+		Audience:       claims.Audience,
+		OrganizationId: claims.OrganizationID,
+	}
+	if claims.IssuedAt != nil {
+		res.IssuedAt = timestamppb.New(claims.IssuedAt.Time)
+	}
+	if claims.ExpiresAt != nil {
+		res.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	return res, nil
+}
+
+// CheckPermission decides like POST /authorize does for a subject
+func (s *GrpcServer) CheckPermission(ctx context.Context, req *authpb.CheckPermissionRequest) (*authpb.CheckPermissionResponse, error) {
+	if req.Permission == "" {
+		return nil, status.Error(codes.InvalidArgument, "permission is required")
+	}
+
+	organizationID, err := requestTenant(ctx, req.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := subjectPermissions(ctx, s.Repo, req.UserId, organizationID)
+	if err != nil {
+		return nil, s.grpcError(ctx, err)
+	}
+
+	switch {
+	case subject.Reason != "":
+		return &authpb.CheckPermissionResponse{Allowed: false, Reason: subject.Reason}, nil
+	case HasPermission(subject.Permissions, req.Permission):
+		return &authpb.CheckPermissionResponse{Allowed: true, Reason: ReasonGranted}, nil
+	}
+	return &authpb.CheckPermissionResponse{Allowed: false, Reason: ReasonPermissionMissing}, nil
+}
+
+func (s *GrpcServer) GetUser(ctx context.Context, req *authpb.GetUserRequest) (*authpb.User, error) {
+	organizationID, err := requestTenant(ctx, req.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.Repo.GetUser(ctx, repository.GetUserParams{ID: int32(req.Id), OrganizationID: organizationID})
+	if err != nil {
+		return nil, s.grpcError(ctx, DBError(err, "user"))
+	}
+
+	return &authpb.User{
+		Id:              int64(user.ID),
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName.String,
+		LastName:        user.LastName.String,
+		PhoneNumber:     user.PhoneNumber.String,
+		PhoneVerified:   user.PhoneVerified.Bool,
+		IsActive:        user.IsActive.Bool,
+		IsVerified:      user.IsVerified.Bool,
+		ApprovalPending: user.ApprovalPending.Bool,
+		Role:            user.Role,
+		CreatedAt:       protoTimestamp(user.CreatedAt),
+		UpdatedAt:       protoTimestamp(user.UpdatedAt),
+		OrganizationId:  user.OrganizationID,
+	}, nil
+}
+
+func (s *GrpcServer) ListUserRoles(ctx context.Context, req *authpb.ListUserRolesRequest) (*authpb.ListUserRolesResponse, error) {
+	organizationID, err := requestTenant(ctx, req.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := tenantUser(ctx, s.Repo, req.UserId, organizationID)
+	if err != nil {
+		return nil, s.grpcError(ctx, DBError(err, "user"))
+	}
+
+	roles, err := userRoles(ctx, s.Repo, user, organizationID)
+	if err != nil {
+		return nil, s.grpcError(ctx, err)
+	}
+
+	res := &authpb.ListUserRolesResponse{Roles: make([]*authpb.Role, 0, len(roles))}
+	for _, role := range roles {
+		res.Roles = append(res.Roles, &authpb.Role{
+			Id:          int64(role.ID),
+			Name:        role.RoleName,
+			Permissions: role.Permissions,
+		})
+	}
+	return res, nil
+}
+
+// requestTenant is the organization a call asks about, the caller's own when
+// unset. Only callers of the default organization may ask about another one.
+func requestTenant(ctx context.Context, organizationID int32) (int32, error) {
+	principal, _ := ctx.Value(grpcPrincipalKey{}).(*authpb.Principal)
+	if principal == nil {
+		return 0, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	callerID := principal.OrganizationId
+	if callerID == 0 {
+		callerID = DefaultOrganizationID
+	}
+	if organizationID == 0 || organizationID == callerID {
+		return callerID, nil
+	}
+	if callerID != DefaultOrganizationID {
+		return 0, status.Error(codes.PermissionDenied, ErrTenantMismatch.Error())
+	}
+	return organizationID, nil
+}
+
+func protoTimestamp(t pgtype.Timestamp) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"users/authpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGrpcClient serves AuthService without a database, enough for calls
+// the auth interceptor refuses
+func newTestGrpcClient(t *testing.T) authpb.AuthServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewGrpcServer(&GrpcServer{
+		Cfg:    &Config{JWTSecret: "test-secret", JWTAudience: []string{"users"}},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return authpb.NewAuthServiceClient(conn)
+}
+
+func TestGrpcRequiresCredentials(t *testing.T) {
+	client := newTestGrpcClient(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"missing", context.Background()},
+		{"malformed header", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic abc")},
+		{"invalid token", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")},
+		{"invalid api key", metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "not-a-key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.GetUser(tt.ctx, &authpb.GetUserRequest{Id: 1})
+			if code := status.Code(err); code != codes.Unauthenticated {
+				t.Errorf("GetUser: code %s, want %s", code, codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestGrpcRefusesOtherTenants(t *testing.T) {
+	server := &GrpcServer{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	ctx := context.WithValue(context.Background(), grpcPrincipalKey{}, &authpb.Principal{
+		UserId:         1,
+		OrganizationId: 2,
+		Permissions:    []string{AuthIntrospectPermission, UsersReadPermission, RolesReadPermission},
+	})
+
+	if _, err := server.GetUser(ctx, &authpb.GetUserRequest{Id: 1, OrganizationId: 3}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("GetUser: code %s, want %s", status.Code(err), codes.PermissionDenied)
+	}
+	if _, err := server.CheckPermission(ctx, &authpb.CheckPermissionRequest{UserId: 1, Permission: UsersReadPermission, OrganizationId: 3}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CheckPermission: code %s, want %s", status.Code(err), codes.PermissionDenied)
+	}
+	if _, err := server.ListUserRoles(ctx, &authpb.ListUserRolesRequest{UserId: 1, OrganizationId: 3}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ListUserRoles: code %s, want %s", status.Code(err), codes.PermissionDenied)
+	}
+
+	token, err := GenerateToken(&Config{JWTSecret: "test-secret", JWTAudience: []string{"users"}}, AccessToken, 5, 1, 3, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Cfg = &Config{JWTSecret: "test-secret", JWTAudience: []string{"users"}}
+	res, err := server.IntrospectToken(ctx, &authpb.IntrospectTokenRequest{Token: token})
+	if err != nil || res.Active {
+		t.Errorf("IntrospectToken of another tenant: active = %v, err = %v", res.GetActive(), err)
+	}
+}
@@ -8,29 +8,39 @@ import (
 	"net/smtp"
 	"strconv"
 	"strings"
+	"time"
 	"users/repository"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	DB     *pgx.Conn
-	Repo   *repository.Queries
-	Logger *slog.Logger
-	Cfg    *Config
-	Ctx    context.Context
+	DB        *pgxpool.Pool
+	Repo      *repository.Queries
+	Logger    *slog.Logger
+	Cfg       *Config
+	Ctx       context.Context
+	Passwords *PasswordPolicy
+	Hasher    *PasswordHasher
+	Sms       SmsSender
+	Routes    *RouteRegistry
 }
 
 // permissions handlers
 
 func (h *AuthHandler) GetAllPermissions(c echo.Context) error {
-	permissions, err := h.Repo.ListPermissions(h.Ctx)
+	var permissions []repository.Permission
+	var err error
+	if c.QueryParam("deleted") == "true" {
+		permissions, err = h.Repo.ListDeletedPermissions(h.Ctx)
+	} else {
+		permissions, err = h.Repo.ListPermissions(h.Ctx)
+	}
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "permission")
 	}
 
 	if permissions == nil {
@@ -51,7 +61,13 @@ func (h *AuthHandler) GetAllPermissions(c echo.Context) error {
 	return NewResponse(c, "success", permissions, "", http.StatusOK)
 }
 
+// CreatePermissions adds to the permissions every organization shares, so only
+// the default organization manages them
 func (h *AuthHandler) CreatePermissions(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
 	data := new(PermissionsDTO)
 	err := c.Bind(data)
 	if err != nil {
@@ -59,12 +75,12 @@ func (h *AuthHandler) CreatePermissions(c echo.Context) error {
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
 	}
 
 	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return err
+		return InternalError(err)
 	}
 	defer tx.Rollback(h.Ctx)
 
@@ -72,33 +88,53 @@ func (h *AuthHandler) CreatePermissions(c echo.Context) error {
 	for _, p := range data.Permissions {
 		_, err := qtx.CreatePermission(h.Ctx, p)
 		if err != nil {
-			return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+			return DBError(err, "permission")
 		}
 	}
 
 	if err := tx.Commit(h.Ctx); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", data, "", http.StatusAccepted)
 }
 
 func (h *AuthHandler) DeletePermissions(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
 	id, _ := strconv.Atoi(c.Param("id"))
 	err := h.Repo.SoftDeletePermission(h.Ctx, int32(id))
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "permission")
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
+func (h *AuthHandler) RestorePermissions(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	restored, err := h.Repo.RestorePermission(h.Ctx, int32(id))
+	return restoreResponse(c, "permission", restored, err)
+}
+
 // roles handlers
 
 func (h *AuthHandler) GetAllRoles(c echo.Context) error {
-	roles, err := h.Repo.ListRoles(h.Ctx)
+	var roles []repository.Role
+	var err error
+	if c.QueryParam("deleted") == "true" {
+		roles, err = h.Repo.ListDeletedRoles(h.Ctx, tenantID(c))
+	} else {
+		roles, err = h.Repo.ListRoles(h.Ctx, tenantID(c))
+	}
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "role")
 	}
 
 	if roles == nil {
@@ -110,28 +146,51 @@ func (h *AuthHandler) GetAllRoles(c echo.Context) error {
 
 func (h *AuthHandler) GetOneRole(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	role, err := h.Repo.GetRole(h.Ctx, int32(id))
+	role, err := h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(id), OrganizationID: tenantID(c)})
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "role")
 	}
 
 	return NewResponse(c, "success", role, "", http.StatusOK)
 }
 
 func (h *AuthHandler) CreateRoles(c echo.Context) error {
-	data := new(repository.CreateRoleParams)
+	data := new(RoleDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
+	}
+
+	if fields := validateRolePermissions(tenantID(c), data.Permissions); fields != nil {
+		return ValidationError(fields)
 	}
 
-	role, err := h.Repo.CreateRole(h.Ctx, *data)
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	role, err := qtx.CreateRole(h.Ctx, repository.CreateRoleParams{
+		OrganizationID: tenantID(c),
+		RoleName:       data.RoleName,
+		Permissions:    rolePermissions(data.Permissions),
+	})
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = recordRoleEvent(h.Ctx, qtx, c, EventRoleCreated, role); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", role, "", http.StatusAccepted)
@@ -139,41 +198,124 @@ func (h *AuthHandler) CreateRoles(c echo.Context) error {
 
 func (h *AuthHandler) UpdateRoles(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	data := new(repository.UpdateRoleParams)
-	data.ID = int32(id)
+	data := new(RoleDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
+	}
+
+	if fields := validateRolePermissions(tenantID(c), data.Permissions); fields != nil {
+		return ValidationError(fields)
 	}
 
-	err = h.Repo.UpdateRole(h.Ctx, *data)
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	role, err := qtx.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	role.RoleName, role.Permissions = data.RoleName, rolePermissions(data.Permissions)
+	err = qtx.UpdateRole(h.Ctx, repository.UpdateRoleParams{
+		ID:             role.ID,
+		RoleName:       role.RoleName,
+		Permissions:    role.Permissions,
+		OrganizationID: role.OrganizationID,
+	})
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = recordRoleEvent(h.Ctx, qtx, c, EventRoleUpdated, role); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusAccepted)
 }
 
+// validateRolePermissions refuses platform permissions in roles outside the
+// default organization
+func validateRolePermissions(organizationID int32, permissions []string) ValidationErrors {
+	if granted := tenantPermissions(organizationID, permissions); len(granted) == len(permissions) {
+		return nil
+	}
+	return ValidationErrors{{
+		Field:   "permissions",
+		Rule:    "tenant",
+		Message: "platform permissions can only be granted in the default organization",
+	}}
+}
+
+// rolePermissions stores a role without permissions as an empty list
+func rolePermissions(permissions []string) []string {
+	if permissions == nil {
+		return make([]string, 0)
+	}
+	return permissions
+}
+
 func (h *AuthHandler) DeleteRoles(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	err := h.Repo.SoftDeleteRole(h.Ctx, int32(id))
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	role, err := qtx.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = qtx.SoftDeleteRole(h.Ctx, repository.SoftDeleteRoleParams{ID: role.ID, OrganizationID: role.OrganizationID}); err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = recordRoleEvent(h.Ctx, qtx, c, EventRoleDeleted, role); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
+func (h *AuthHandler) RestoreRoles(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	restored, err := h.Repo.RestoreRole(h.Ctx, repository.RestoreRoleParams{ID: int32(id), OrganizationID: tenantID(c)})
+	return restoreResponse(c, "role", restored, err)
+}
+
 // users handlers
 
+// listUsers applies the user listing filters, shared by listing and export
+func (h *AuthHandler) listUsers(c echo.Context) ([]repository.User, error) {
+	if c.QueryParam("deleted") == "true" {
+		return h.Repo.ListDeletedUsers(h.Ctx, tenantID(c))
+	}
+	return h.Repo.ListUsers(h.Ctx, tenantID(c))
+}
+
 func (h *AuthHandler) GetAllUsers(c echo.Context) error {
-	users, err := h.Repo.ListUsers(h.Ctx)
+	users, err := h.listUsers(c)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "user")
 	}
 
 	if users == nil {
@@ -183,18 +325,22 @@ func (h *AuthHandler) GetAllUsers(c echo.Context) error {
 	usersDTO := make([]UserGetDTO, 0)
 	for _, user := range users {
 		usersDTO = append(usersDTO, UserGetDTO{
-			ID:          user.ID,
-			Username:    user.Username,
-			Email:       user.Email,
-			FirstName:   user.FirstName,
-			LastName:    user.LastName,
-			PhoneNumber: user.PhoneNumber,
-			IsActive:    user.IsActive,
-			IsVerified:  user.IsVerified,
-			Role:        user.Role,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			DeletedAt:   user.DeletedAt,
+			ID:              user.ID,
+			OrganizationID:  user.OrganizationID,
+			Username:        user.Username,
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			PhoneNumber:     user.PhoneNumber,
+			PhoneVerified:   user.PhoneVerified,
+			SmsTwoFactor:    user.SmsTwoFactor,
+			IsActive:        user.IsActive,
+			IsVerified:      user.IsVerified,
+			ApprovalPending: user.ApprovalPending,
+			Role:            user.Role,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+			DeletedAt:       user.DeletedAt,
 		})
 	}
 
@@ -203,93 +349,221 @@ func (h *AuthHandler) GetAllUsers(c echo.Context) error {
 
 func (h *AuthHandler) GetOneUser(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	user, err := h.Repo.GetUser(h.Ctx, int32(id))
+	user, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: int32(id), OrganizationID: tenantID(c)})
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return DBError(err, "user")
 	}
 
 	userDTO := UserGetDTO{
-		ID:          user.ID,
-		Username:    user.Username,
-		Email:       user.Email,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		PhoneNumber: user.PhoneNumber,
-		IsActive:    user.IsActive,
-		IsVerified:  user.IsVerified,
-		Role:        user.Role,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		DeletedAt:   user.DeletedAt,
+		ID:              user.ID,
+		OrganizationID:  user.OrganizationID,
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		SmsTwoFactor:    user.SmsTwoFactor,
+		IsActive:        user.IsActive,
+		IsVerified:      user.IsVerified,
+		ApprovalPending: user.ApprovalPending,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
 	}
 
 	return NewResponse(c, "success", userDTO, "", http.StatusOK)
 }
 
 func (h *AuthHandler) CreateUsers(c echo.Context) error {
-	data := new(repository.CreateUserParams)
+	data := new(CreateUserDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
+	}
+
+	params := repository.CreateUserParams{
+		OrganizationID: tenantID(c),
+		Username:       data.Username,
+		Email:          data.Email,
+		FirstName:      pgtype.Text{String: data.FirstName, Valid: data.FirstName != ""},
+		LastName:       pgtype.Text{String: data.LastName, Valid: data.LastName != ""},
+		PhoneNumber:    pgtype.Text{String: data.PhoneNumber, Valid: true},
+		IsActive:       pgtype.Bool{Bool: data.IsActive, Valid: true},
+		IsVerified:     pgtype.Bool{Bool: data.IsVerified, Valid: true},
+		Role:           data.Role,
+	}
+
+	if err = h.normalizePhoneNumber(&params.PhoneNumber); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	// roles of other organizations grant nothing here
+	if _, err = h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(data.Role), OrganizationID: tenantID(c)}); err != nil {
+		return DBError(err, "role")
+	}
+
+	if err = h.Passwords.Validate(data.Password, data.Username, data.Email); err != nil {
+		return NewValidationResponse(c, err)
 	}
 
-	hashedPassword, err := HashPassword(data.Password)
+	hashedPassword, err := h.Hasher.Hash(data.Password)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
 	}
-	data.Password = hashedPassword
+	params.Password = hashedPassword
 
-	user, err := h.Repo.CreateUser(h.Ctx, *data)
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	user, err := qtx.CreateUser(h.Ctx, params)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = h.recordPassword(qtx, user.ID, hashedPassword); err != nil {
+		return DBError(err, "password history")
+	}
+
+	if err = recordUserRegistered(h.Ctx, qtx, c, user, RegistrationSourceAdmin); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	userDTO := UserGetDTO{
-		ID:          user.ID,
-		Username:    user.Username,
-		Email:       user.Email,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		PhoneNumber: user.PhoneNumber,
-		IsActive:    user.IsActive,
-		IsVerified:  user.IsVerified,
-		Role:        user.Role,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		DeletedAt:   user.DeletedAt,
+		ID:              user.ID,
+		OrganizationID:  user.OrganizationID,
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		SmsTwoFactor:    user.SmsTwoFactor,
+		IsActive:        user.IsActive,
+		IsVerified:      user.IsVerified,
+		ApprovalPending: user.ApprovalPending,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
 	}
 
 	return NewResponse(c, "success", userDTO, "", http.StatusAccepted)
 }
 
+// UpdateUsers never touches the email, changes go through the confirmed
+// email change flow
 func (h *AuthHandler) UpdateUsers(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	data := new(repository.UpdateUserParams)
-	data.ID = int32(id)
+	data := new(UpdateUserDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
 	}
 
+	user, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	params := repository.UpdateUserParams{
+		ID:             user.ID,
+		Username:       data.Username,
+		FirstName:      pgtype.Text{String: data.FirstName, Valid: data.FirstName != ""},
+		LastName:       pgtype.Text{String: data.LastName, Valid: data.LastName != ""},
+		PhoneNumber:    pgtype.Text{String: data.PhoneNumber, Valid: true},
+		IsActive:       user.IsActive,
+		IsVerified:     user.IsVerified,
+		Role:           data.Role,
+		OrganizationID: user.OrganizationID,
+	}
+	if data.IsActive != nil {
+		params.IsActive = pgtype.Bool{Bool: *data.IsActive, Valid: true}
+	}
+	if data.IsVerified != nil {
+		params.IsVerified = pgtype.Bool{Bool: *data.IsVerified, Valid: true}
+	}
+
+	if err = h.normalizePhoneNumber(&params.PhoneNumber); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	if _, err = h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(data.Role), OrganizationID: user.OrganizationID}); err != nil {
+		return DBError(err, "role")
+	}
+
+	hashedPassword := ""
 	if data.Password != "" {
-		hashedPassword, err := HashPassword(data.Password)
+		if err = h.Passwords.Validate(data.Password, data.Username, user.Email); err != nil {
+			return NewValidationResponse(c, err)
+		}
+
+		if err = h.checkPasswordReuse(user.ID, data.Password); err != nil {
+			return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		hashedPassword, err = h.Hasher.Hash(data.Password)
 		if err != nil {
-			return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+			return InternalError(err)
 		}
-		data.Password = hashedPassword
 	}
 
-	err = h.Repo.UpdateUser(h.Ctx, *data)
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	if err = qtx.UpdateUser(h.Ctx, params); err != nil {
+		return DBError(err, "user")
+	}
+
+	if hashedPassword != "" {
+		err = qtx.UpdateUserPassword(h.Ctx, repository.UpdateUserPasswordParams{
+			ID:             user.ID,
+			Password:       hashedPassword,
+			OrganizationID: user.OrganizationID,
+		})
+		if err != nil {
+			return DBError(err, "user")
+		}
+
+		if err = h.recordPassword(qtx, user.ID, hashedPassword); err != nil {
+			return DBError(err, "password history")
+		}
+	}
+
+	// deactivating ends the sessions at once, not when their tokens expire
+	if user.IsActive.Bool && !params.IsActive.Bool {
+		if err = qtx.RevokeUserSessions(h.Ctx, user.ID); err != nil {
+			return DBError(err, "session")
+		}
+	}
+
+	if err = recordUserChanges(h.Ctx, qtx, c, user, params.Role, params.IsActive.Bool); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusAccepted)
@@ -297,28 +571,57 @@ func (h *AuthHandler) UpdateUsers(c echo.Context) error {
 
 func (h *AuthHandler) DeleteUsers(c echo.Context) error {
 	id, _ := strconv.Atoi(c.Param("id"))
-	err := h.Repo.SoftDeleteUser(h.Ctx, int32(id))
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusInternalServerError)
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	user, err := qtx.GetUser(h.Ctx, repository.GetUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = qtx.SoftDeleteUser(h.Ctx, repository.SoftDeleteUserParams{ID: user.ID, OrganizationID: user.OrganizationID}); err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = qtx.RevokeUserSessions(h.Ctx, user.ID); err != nil {
+		return DBError(err, "session")
+	}
+
+	if err = recordEvent(h.Ctx, qtx, c, EventUserDeleted, userSubject(user.ID), UserEventData{UserID: user.ID}); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
-// auth handlers
-func VerifyPassword(password string, hashedPassword string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+func (h *AuthHandler) RestoreUsers(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	restored, err := h.Repo.RestoreUser(h.Ctx, repository.RestoreUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	return restoreResponse(c, "user", restored, err)
 }
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// restoreResponse maps the outcome of a restore query, a live record already
+// holding the same unique name or email makes the restore conflict
+func restoreResponse(c echo.Context, kind string, restored int64, err error) error {
 	if err != nil {
-		return "", err
+		return DBError(err, kind)
+	}
+	if restored == 0 {
+		return NotFoundError(fmt.Sprintf("deleted %s not found", kind))
 	}
-	return string(hashedPassword), nil
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
+// auth handlers
 func SendEmail(from, password, to, subject, body string) error {
 	smtpHost := "smtp.gmail.com"
 	smtpPort := "587"
@@ -328,119 +631,173 @@ func SendEmail(from, password, to, subject, body string) error {
 	return err
 }
 
-// create a seed for all permissions
-func CreatePermissionsSeed(DB *repository.Queries) error {
-	permissions := []string{"users:read", "users:create", "users:update", "users:delete", "roles:list", "roles:read", "roles:create", "roles:update", "roles:delete", "permissions:create", "permissions:delete", "permissions:list"}
-	for _, permission := range permissions {
-		_, err := DB.CreatePermission(context.Background(), permission)
-		if err != nil {
-			return err
-		}
+func (h *AuthHandler) VerifyEmail(c echo.Context) error {
+	token := c.QueryParam("token")
+	if token == "" {
+		return NewResponse(c, "failed", nil, "token is required", http.StatusBadRequest)
 	}
-	return nil
-}
 
-func CreateSuperAdmin(DB *repository.Queries) error {
-	role, err := DB.CreateRole(context.Background(), repository.CreateRoleParams{
-		RoleName:    "superadmin",
-		Permissions: []string{"users:read", "users:create", "users:update", "users:delete", "roles:list", "roles:read", "roles:create", "roles:update", "roles:delete", "permissions:create", "permissions:delete", "permissions:list"},
-	})
+	// extract user id from token
+	claims, err := ParseToken(h.Cfg, token, VerifyEmailToken)
 	if err != nil {
-		return err
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
-	hashedPassword, err := HashPassword("superadmin")
+	tx, err := h.DB.Begin(h.Ctx)
 	if err != nil {
-		return err
+		return InternalError(err)
 	}
+	defer tx.Rollback(h.Ctx)
 
-	_, err = DB.CreateUser(context.Background(), repository.CreateUserParams{
-		Username:    "superadmin",
-		Email:       "superadmin@email.com",
-		Password:    hashedPassword,
-		Role:        int64(role.ID),
-		IsActive:    pgtype.Bool{Bool: true, Valid: true},
-		IsVerified:  pgtype.Bool{Bool: true, Valid: true},
-		FirstName:   pgtype.Text{String: "Super", Valid: true},
-		LastName:    pgtype.Text{String: "Admin", Valid: true},
-		PhoneNumber: pgtype.Text{String: "1234567890", Valid: true},
-	})
+	qtx := h.Repo.WithTx(tx)
+	user, err := qtx.GetUser(h.Ctx, repository.GetUserParams{ID: int32(claims.UserID), OrganizationID: claims.OrganizationID})
 	if err != nil {
-		return err
+		return DBError(err, "user")
 	}
 
-	return nil
-}
+	// following the link again verifies nothing new
+	if !user.IsVerified.Bool {
+		if err = qtx.VerifyUser(h.Ctx, repository.VerifyUserParams{ID: user.ID, OrganizationID: user.OrganizationID}); err != nil {
+			return DBError(err, "user")
+		}
 
-func (h *AuthHandler) VerifyEmail(c echo.Context) error {
-	token := c.QueryParam("token")
-	if token == "" {
-		return NewResponse(c, "failed", nil, "token is required", http.StatusBadRequest)
+		if err = recordOrganizationEvent(h.Ctx, qtx, c, user.OrganizationID, EventUserVerified, userSubject(user.ID), UserEventData{UserID: user.ID}); err != nil {
+			return InternalError(err)
+		}
 	}
 
-	// extract user id from token
-	userID := 1
-	err := h.Repo.UpdateUser(h.Ctx, repository.UpdateUserParams{
-		ID:         int32(userID),
-		IsVerified: pgtype.Bool{Bool: true, Valid: true},
-	})
-	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
 func (h *AuthHandler) Register(c echo.Context) error {
-	data := new(repository.CreateUserParams)
+	data := new(RegisterDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
+	}
+
+	if err = h.checkRegistration(tenantID(c), data.Email); err != nil {
+		return err
+	}
+
+	// self registered users get the default role, they stay inactive while
+	// waiting for approval
+	params := repository.CreateUserParams{
+		OrganizationID: tenantID(c),
+		Username:       data.Username,
+		Email:          data.Email,
+		FirstName:      pgtype.Text{String: data.FirstName, Valid: data.FirstName != ""},
+		LastName:       pgtype.Text{String: data.LastName, Valid: data.LastName != ""},
+		PhoneNumber:    pgtype.Text{String: data.PhoneNumber, Valid: true},
+		IsActive:       pgtype.Bool{Bool: !h.Cfg.RegistrationApproval, Valid: true},
+		IsVerified:     pgtype.Bool{Bool: false, Valid: true},
+	}
+
+	if err = h.normalizePhoneNumber(&params.PhoneNumber); err != nil {
+		return NewValidationResponse(c, err)
 	}
 
-	data.IsActive = pgtype.Bool{Bool: false, Valid: true}
-	data.IsVerified = pgtype.Bool{Bool: false, Valid: true}
+	if err = h.Passwords.Validate(data.Password, data.Username, data.Email); err != nil {
+		return NewValidationResponse(c, err)
+	}
 
-	hashedPassword, err := HashPassword(data.Password)
+	role, err := h.Repo.GetRoleByName(h.Ctx, repository.GetRoleByNameParams{OrganizationID: tenantID(c), RoleName: h.Cfg.DefaultRole})
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(fmt.Errorf("default role %s: %w", h.Cfg.DefaultRole, err))
 	}
-	data.Password = hashedPassword
+	params.Role = int64(role.ID)
 
-	user, err := h.Repo.CreateUser(h.Ctx, *data)
+	hashedPassword, err := h.Hasher.Hash(data.Password)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
 	}
+	params.Password = hashedPassword
 
-	verificationLink := fmt.Sprintf("%s/verify-email?token=%v", h.Cfg.AppAddr, user.ID)
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	user, err := qtx.CreateUser(h.Ctx, params)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if h.Cfg.RegistrationApproval {
+		if err = qtx.RequireUserApproval(h.Ctx, repository.RequireUserApprovalParams{ID: user.ID, OrganizationID: user.OrganizationID}); err != nil {
+			return DBError(err, "user")
+		}
+		user.ApprovalPending = pgtype.Bool{Bool: true, Valid: true}
+	}
+
+	if err = h.recordPassword(qtx, user.ID, hashedPassword); err != nil {
+		return DBError(err, "password history")
+	}
+
+	if err = recordUserRegistered(h.Ctx, qtx, c, user, RegistrationSourceSelf); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	verificationToken, err := GenerateToken(h.Cfg, VerifyEmailToken, int64(user.ID), user.Role, user.OrganizationID, 0, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	verificationLink := fmt.Sprintf("%s/v1/auth/verify-email?token=%s", h.Cfg.AppAddr, verificationToken)
 	verificationBody := fmt.Sprintf("<a href=\"%s\">Verify Email</a>", verificationLink)
 	err = SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, user.Email, "Verify Email", verificationBody)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
 	}
 
 	userDTO := UserGetDTO{
-		ID:          user.ID,
-		Username:    user.Username,
-		Email:       user.Email,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		PhoneNumber: user.PhoneNumber,
-		IsActive:    user.IsActive,
-		IsVerified:  user.IsVerified,
-		Role:        user.Role,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		DeletedAt:   user.DeletedAt,
+		ID:              user.ID,
+		OrganizationID:  user.OrganizationID,
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		SmsTwoFactor:    user.SmsTwoFactor,
+		IsActive:        user.IsActive,
+		IsVerified:      user.IsVerified,
+		ApprovalPending: user.ApprovalPending,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
 	}
 
 	return NewResponse(c, "success", userDTO, "", http.StatusOK)
 }
 
+// canSignIn refuses sessions to deactivated users and to users an
+// administrator has not approved yet
+func canSignIn(user repository.User) error {
+	if !user.IsActive.Bool {
+		return &DomainError{Status: http.StatusForbidden, Code: CodeAccountInactive, Message: "account is deactivated"}
+	}
+	if user.ApprovalPending.Bool {
+		return &DomainError{Status: http.StatusForbidden, Code: CodeApprovalPending, Message: "account is waiting for approval by an administrator"}
+	}
+	return nil
+}
+
 func (h *AuthHandler) Login(c echo.Context) error {
 	// take username or email and password
 	data := new(LoginDTO)
@@ -450,85 +807,256 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	}
 
 	if err = c.Validate(data); err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+		return NewValidationResponse(c, err)
 	}
 
-	// check if user exists by user email
-	user, err := h.Repo.GetUserByEmail(h.Ctx, data.Email)
+	// check if user exists by user email, in the organization or as a member
+	user, err := h.Repo.GetUserByEmail(h.Ctx, repository.GetUserByEmailParams{OrganizationID: tenantID(c), Email: data.Email})
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return NewResponse(c, "failed", nil, "invalid email or password", http.StatusBadRequest)
 	}
 
 	// check if password is correct
-	if !VerifyPassword(data.Password, user.Password) {
+	ok, needsRehash := h.Hasher.Verify(data.Password, user.Password)
+	if !ok {
 		return NewResponse(c, "failed", nil, "invalid email or password", http.StatusBadRequest)
 	}
 
-	// get user role and permissions
-	role, err := h.Repo.GetRole(h.Ctx, int32(user.Role))
+	// upgrade hashes made with an older algorithm or parameters while we have the plain password
+	if needsRehash {
+		if hashedPassword, err := h.Hasher.Hash(data.Password); err == nil {
+			err = h.Repo.UpdateUserPasswordHash(h.Ctx, repository.UpdateUserPasswordHashParams{
+				ID:             user.ID,
+				Password:       hashedPassword,
+				OrganizationID: user.OrganizationID,
+			})
+			if err != nil {
+				h.Logger.Error("failed to rehash password: ", "error", err)
+			}
+		}
+	}
+
+	if err = canSignIn(user); err != nil {
+		return err
+	}
+
+	// force rotation of passwords older than the max age
+	if h.Passwords.Expired(user.PasswordChangedAt) {
+		return NewResponse(c, "failed", nil, "password has expired, reset it using forgot-password", http.StatusForbidden)
+	}
+
+	// a verified phone can be required as second factor
+	if user.SmsTwoFactor.Bool && user.PhoneVerified.Bool && user.PhoneNumber.Valid {
+		return h.startSmsChallenge(c, user)
+	}
+
+	if user, err = withTenantRole(h.Ctx, h.Repo, user, tenantID(c)); err != nil {
+		return DBError(err, "member")
+	}
+	return h.startSession(c, user, data.Device)
+}
+
+// startSession records a new session for an authenticated user and returns
+// its access and refresh tokens
+func (h *AuthHandler) startSession(c echo.Context, user repository.User, device string) error {
+	// permissions of the user's role in the tenant and of their groups
+	permissions, err := userPermissions(h.Ctx, h.Repo, user, tenantID(c))
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return err
 	}
 
-	permissions := role.Permissions
+	// record the session the tokens belong to, with the id of the one
+	// refresh token it accepts
+	refreshID := uuid.New().String()
+	params := NewSessionParams(c, h.Cfg, user.ID, tenantID(c), device)
+	params.RefreshTokenID = pgtype.Text{String: refreshID, Valid: true}
+	session, err := h.Repo.CreateSession(h.Ctx, params)
+	if err != nil {
+		return DBError(err, "session")
+	}
 
-	// generate token
-	token, err := GenerateToken(h.Cfg.JWTSecret, int64(user.ID), int64(user.Role), 10, permissions)
+	// generate tokens
+	token, err := GenerateToken(h.Cfg, AccessToken, int64(user.ID), user.Role, tenantID(c), session.ID, permissions)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+
+	refreshToken, err := signToken(h.Cfg, RefreshToken, int64(user.ID), user.Role, tenantID(c), session.ID, nil, refreshID)
+	if err != nil {
+		return InternalError(err)
 	}
 
 	userDTO := UserGetDTO{
-		ID:          user.ID,
-		Username:    user.Username,
-		Email:       user.Email,
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		PhoneNumber: user.PhoneNumber,
-		IsActive:    user.IsActive,
-		IsVerified:  user.IsVerified,
-		Role:        user.Role,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		DeletedAt:   user.DeletedAt,
+		ID:              user.ID,
+		OrganizationID:  user.OrganizationID,
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		SmsTwoFactor:    user.SmsTwoFactor,
+		IsActive:        user.IsActive,
+		IsVerified:      user.IsVerified,
+		ApprovalPending: user.ApprovalPending,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
 	}
 
 	// return token
-	responseData := map[string]interface{}{
-		"token": token,
-		"user":  userDTO,
+	responseData := LoginResponseDTO{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         userDTO,
 	}
 	return NewResponse(c, "success", responseData, "", http.StatusOK)
 }
 
-// TODO: implement refresh token
-func (h *AuthHandler) RefreshToken(c echo.Context) error {
+func (h *AuthHandler) ForgotPassword(c echo.Context) error {
+	data := new(ForgotPasswordDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	// do not reveal whether the email is registered
+	user, err := h.Repo.GetUserByEmail(h.Ctx, repository.GetUserByEmailParams{OrganizationID: tenantID(c), Email: data.Email})
+	if err != nil {
+		return NewResponse(c, "success", nil, "", http.StatusOK)
+	}
+
+	// failures are only logged and the mail goes out in the background, so
+	// neither the answer nor its timing tell registered emails apart
+	resetToken, err := h.createPasswordReset(user)
+	if err != nil {
+		h.Logger.Error("failed to create password reset: ", "error", err)
+		return NewResponse(c, "success", nil, "", http.StatusOK)
+	}
+
+	resetPasswordBody := fmt.Sprintf("Use this token to reset your password: %s", resetToken)
+	go func() {
+		if err := SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, user.Email, "Reset Password", resetPasswordBody); err != nil {
+			h.Logger.Error("failed to send password reset email: ", "error", err)
+		}
+	}()
+
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
-func (h *AuthHandler) ForgotPassword(c echo.Context) error {
-	data := new(ForgotPasswordDTO)
+// createPasswordReset stores the hash of a single use reset token, the token
+// itself is only mailed
+func (h *AuthHandler) createPasswordReset(user repository.User) (string, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = h.Repo.CreatePasswordReset(h.Ctx, repository.CreatePasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: pgtype.Timestamp{Time: time.Now().Add(h.Cfg.ResetPasswordTokenTTL), Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetPassword uses up every open reset token of the user, not only the one
+// it was given
+func (h *AuthHandler) ResetPassword(c echo.Context) error {
+	data := new(ResetPasswordDTO)
 	err := c.Bind(data)
 	if err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
 	}
 
 	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	reset, err := h.Repo.GetPendingPasswordResetByTokenHash(h.Ctx, HashApiKey(data.Token))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "invalid or expired token", http.StatusBadRequest)
+	}
+
+	// the password belongs to the account, whatever the tenant of the request
+	organizationID, err := h.Repo.GetUserOrganizationID(h.Ctx, reset.UserID)
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	user, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: reset.UserID, OrganizationID: organizationID})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = h.Passwords.Validate(data.Password, user.Username, user.Email); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	if err = h.checkPasswordReuse(user.ID, data.Password); err != nil {
 		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
 	}
 
-	// generate random password
-	password := uuid.New().String()[:9]
-	resetPasswordBody := fmt.Sprintf("Your new password is: %s", password)
-	err = SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, data.Email, "Reset Password", resetPasswordBody)
+	hashedPassword, err := h.Hasher.Hash(data.Password)
 	if err != nil {
-		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+		return InternalError(err)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	used, err := qtx.UseUserPasswordResets(h.Ctx, user.ID)
+	if err != nil {
+		return DBError(err, "password reset")
+	}
+	if used == 0 {
+		return NewResponse(c, "failed", nil, "invalid or expired token", http.StatusBadRequest)
+	}
+
+	err = qtx.UpdateUserPassword(h.Ctx, repository.UpdateUserPasswordParams{
+		ID:             user.ID,
+		Password:       hashedPassword,
+		OrganizationID: user.OrganizationID,
+	})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = h.recordPassword(qtx, user.ID, hashedPassword); err != nil {
+		return DBError(err, "password history")
+	}
+
+	// a reset logs out every device
+	if err = qtx.RevokeUserSessions(h.Ctx, user.ID); err != nil {
+		return DBError(err, "session")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
 	}
 
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
 
-// TODO: implement logout
 func (h *AuthHandler) Logout(c echo.Context) error {
+	sessionID, _ := c.Get("sessionID").(int32)
+	if sessionID != 0 {
+		if err := h.Repo.RevokeSession(h.Ctx, sessionID); err != nil {
+			return DBError(err, "session")
+		}
+	}
+
 	return NewResponse(c, "success", nil, "", http.StatusOK)
 }
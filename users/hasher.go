@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// PasswordHasher produces self describing hashes: argon2id hashes use the PHC
+// string format ($argon2id$v=19$m=..,t=..,p=..$salt$hash) and bcrypt hashes
+// carry their cost, so verification works for every algorithm and parameter
+// set we ever used
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+func NewPasswordHasher(cfg *Config) *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:  cfg.PasswordHashAlgorithm,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: Argon2Params{
+			Time:    cfg.Argon2Time,
+			Memory:  cfg.Argon2Memory,
+			Threads: cfg.Argon2Threads,
+			KeyLen:  32,
+			SaltLen: 16,
+		},
+	}
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == Bcrypt {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashedPassword), nil
+	}
+
+	salt := make([]byte, h.Argon2.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Argon2.Time, h.Argon2.Memory, h.Argon2.Threads, h.Argon2.KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id, argon2.Version, h.Argon2.Memory, h.Argon2.Time, h.Argon2.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks a password against a hash of any supported format and reports
+// whether the hash should be replaced because it uses outdated parameters
+func (h *PasswordHasher) Verify(password, hashedPassword string) (ok bool, needsRehash bool) {
+	if strings.HasPrefix(hashedPassword, "$"+Argon2id+"$") {
+		params, salt, key, err := decodeArgon2id(hashedPassword)
+		if err != nil {
+			return false, false
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false
+		}
+
+		outdated := h.Algorithm != Argon2id ||
+			params.Time != h.Argon2.Time ||
+			params.Memory != h.Argon2.Memory ||
+			params.Threads != h.Argon2.Threads ||
+			uint32(len(key)) != h.Argon2.KeyLen
+		return true, outdated
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return true, h.Algorithm != Bcrypt || err != nil || cost != h.BcryptCost
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+	return params, salt, key, nil
+}
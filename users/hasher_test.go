@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestHasher keeps the costs low, the tests check formats not strength
+func newTestHasher(algorithm string) *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:  algorithm,
+		BcryptCost: bcrypt.MinCost,
+		Argon2:     Argon2Params{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16},
+	}
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{Argon2id, Bcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher := newTestHasher(algorithm)
+			hash, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, rehash := hasher.Verify("correct horse", hash); !ok || rehash {
+				t.Errorf("Verify(right password) = %v, %v, want true, false", ok, rehash)
+			}
+			if ok, _ := hasher.Verify("wrong horse", hash); ok {
+				t.Error("Verify(wrong password) = true")
+			}
+
+			other, err := hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == hash {
+				t.Error("two hashes of a password are equal, the salt is not random")
+			}
+		})
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	hash, err := newTestHasher(Argon2id).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("hash = %s, want the PHC string format", hash)
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Argon2Params{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}
+	if params != want || len(salt) != 16 || len(key) != 32 {
+		t.Errorf("params = %+v, salt %d bytes, key %d bytes", params, len(salt), len(key))
+	}
+
+	parts := strings.Split(hash, "$")
+	for name, malformed := range map[string]string{
+		"missing part":  strings.Join(parts[:5], "$"),
+		"other version": strings.Replace(hash, "v=19", "v=16", 1),
+		"bad params":    strings.Replace(hash, "m=64,t=1,p=1", "m=64;t=1", 1),
+		"bad salt":      strings.Replace(hash, parts[4], "!!!", 1),
+		"bad key":       strings.Replace(hash, parts[5], "!!!", 1),
+	} {
+		if _, _, _, err = decodeArgon2id(malformed); !errors.Is(err, ErrUnknownHashFormat) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+		if ok, _ := newTestHasher(Argon2id).Verify("correct horse", malformed); ok {
+			t.Errorf("%s: a malformed hash verified", name)
+		}
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	argon2Hash, err := newTestHasher(Argon2id).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := newTestHasher(Bcrypt).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moreTime := newTestHasher(Argon2id)
+	moreTime.Argon2.Time = 2
+	moreMemory := newTestHasher(Argon2id)
+	moreMemory.Argon2.Memory = 128
+	moreThreads := newTestHasher(Argon2id)
+	moreThreads.Argon2.Threads = 2
+	longerKey := newTestHasher(Argon2id)
+	longerKey.Argon2.KeyLen = 64
+	higherCost := newTestHasher(Bcrypt)
+	higherCost.BcryptCost = bcrypt.MinCost + 1
+
+	tests := []struct {
+		name   string
+		hasher *PasswordHasher
+		hash   string
+	}{
+		{"argon2id time", moreTime, argon2Hash},
+		{"argon2id memory", moreMemory, argon2Hash},
+		{"argon2id threads", moreThreads, argon2Hash},
+		{"argon2id key length", longerKey, argon2Hash},
+		{"argon2id to bcrypt", newTestHasher(Bcrypt), argon2Hash},
+		{"bcrypt cost", higherCost, bcryptHash},
+		{"bcrypt to argon2id", newTestHasher(Argon2id), bcryptHash},
+	}
+	for _, tt := range tests {
+		ok, rehash := tt.hasher.Verify("correct horse", tt.hash)
+		if !ok || !rehash {
+			t.Errorf("%s: Verify = %v, %v, want true, true", tt.name, ok, rehash)
+		}
+	}
+}
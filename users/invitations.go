@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+func newInvitationDTO(invitation repository.Invitation) InvitationDTO {
+	return InvitationDTO{
+		ID:         invitation.ID,
+		Email:      invitation.Email,
+		Role:       invitation.Role,
+		InvitedBy:  invitation.InvitedBy,
+		ExpiresAt:  invitation.ExpiresAt,
+		AcceptedAt: invitation.AcceptedAt,
+		RevokedAt:  invitation.RevokedAt,
+		CreatedAt:  invitation.CreatedAt,
+		UpdatedAt:  invitation.UpdatedAt,
+	}
+}
+
+func (h *AuthHandler) sendInvitation(invitation repository.Invitation, token string) error {
+	link := fmt.Sprintf("%s?token=%s", h.Cfg.InvitationURL, token)
+	body := fmt.Sprintf("You have been invited to create an account, or to join with the one you have. <a href=\"%s\">Accept the invitation</a>, the link expires on %s.",
+		link, invitation.ExpiresAt.Time.Format(time.RFC1123))
+	return SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, invitation.Email, "Invitation", body)
+}
+
+// invitations handlers
+
+func (h *AuthHandler) ListInvitations(c echo.Context) error {
+	var invitations []repository.Invitation
+	var err error
+	if c.QueryParam("pending") == "true" {
+		invitations, err = h.Repo.ListPendingInvitations(h.Ctx, tenantID(c))
+	} else {
+		invitations, err = h.Repo.ListInvitations(h.Ctx, tenantID(c))
+	}
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+
+	invitationsDTO := make([]InvitationDTO, 0)
+	for _, invitation := range invitations {
+		invitationsDTO = append(invitationsDTO, newInvitationDTO(invitation))
+	}
+
+	return NewResponse(c, "success", invitationsDTO, "", http.StatusOK)
+}
+
+// CreateInvitation replaces any open invitation for the same address
+func (h *AuthHandler) CreateInvitation(c echo.Context) error {
+	data := new(CreateInvitationDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	if _, err = h.Repo.GetLiveUserByEmail(h.Ctx, repository.GetLiveUserByEmailParams{OrganizationID: tenantID(c), Email: data.Email}); err == nil {
+		return NewResponse(c, "failed", nil, "a user with this email already exists", http.StatusConflict)
+	}
+
+	if _, err = h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(data.Role), OrganizationID: tenantID(c)}); err != nil {
+		return NewResponse(c, "failed", nil, "role not found", http.StatusUnprocessableEntity)
+	}
+
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	err = qtx.RevokeEmailInvitations(h.Ctx, repository.RevokeEmailInvitationsParams{OrganizationID: tenantID(c), Email: data.Email})
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	invitation, err := qtx.CreateInvitation(h.Ctx, repository.CreateInvitationParams{
+		OrganizationID: tenantID(c),
+		Email:          data.Email,
+		Role:           data.Role,
+		TokenHash:      hash,
+		InvitedBy:      pgtype.Int4{Int32: int32(userID), Valid: userID != 0},
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(h.Cfg.InvitationTTL), Valid: true},
+	})
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	if err = h.sendInvitation(invitation, token); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", newInvitationDTO(invitation), "", http.StatusAccepted)
+}
+
+// ResendInvitation issues a fresh token and expiry, the previous link stops working
+func (h *AuthHandler) ResendInvitation(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	invitation, err := h.Repo.RenewInvitation(h.Ctx, repository.RenewInvitationParams{
+		ID:             int32(id),
+		TokenHash:      hash,
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(h.Cfg.InvitationTTL), Valid: true},
+		OrganizationID: tenantID(c),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NewResponse(c, "failed", nil, "open invitation not found", http.StatusNotFound)
+	}
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+
+	if err = h.sendInvitation(invitation, token); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", newInvitationDTO(invitation), "", http.StatusOK)
+}
+
+func (h *AuthHandler) RevokeInvitation(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	revoked, err := h.Repo.RevokeInvitation(h.Ctx, repository.RevokeInvitationParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+	if revoked == 0 {
+		return NewResponse(c, "failed", nil, "open invitation not found", http.StatusNotFound)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+// AcceptInvitation creates the invited user with the password they chose. The
+// account is active and verified since the invitee proved the address by
+// following the link.
+func (h *AuthHandler) AcceptInvitation(c echo.Context) error {
+	data := new(AcceptInvitationDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	invitation, err := h.Repo.GetPendingInvitationByTokenHash(h.Ctx, HashApiKey(data.Token))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "invalid or expired invitation", http.StatusBadRequest)
+	}
+
+	phone := pgtype.Text{String: data.PhoneNumber, Valid: true}
+	if err = h.normalizePhoneNumber(&phone); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	if err = h.Passwords.Validate(data.Password, data.Username, invitation.Email); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	hashedPassword, err := h.Hasher.Hash(data.Password)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	accepted, err := qtx.AcceptInvitation(h.Ctx, invitation.ID)
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+	if accepted == 0 {
+		return NewResponse(c, "failed", nil, "invalid or expired invitation", http.StatusBadRequest)
+	}
+
+	// the account joins the organization that invited it, whatever the tenant of the link
+	user, err := qtx.CreateUser(h.Ctx, repository.CreateUserParams{
+		OrganizationID: invitation.OrganizationID,
+		Username:       data.Username,
+		Email:          invitation.Email,
+		Password:       hashedPassword,
+		FirstName:      pgtype.Text{String: data.FirstName, Valid: data.FirstName != ""},
+		LastName:       pgtype.Text{String: data.LastName, Valid: data.LastName != ""},
+		PhoneNumber:    phone,
+		IsActive:       pgtype.Bool{Bool: true, Valid: true},
+		IsVerified:     pgtype.Bool{Bool: true, Valid: true},
+		Role:           invitation.Role,
+	})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	if err = h.recordPassword(qtx, user.ID, hashedPassword); err != nil {
+		return DBError(err, "password history")
+	}
+
+	if err = recordUserRegistered(h.Ctx, qtx, c, user, RegistrationSourceInvitation); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	userDTO := UserGetDTO{
+		ID:              user.ID,
+		OrganizationID:  user.OrganizationID,
+		Username:        user.Username,
+		Email:           user.Email,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		PhoneVerified:   user.PhoneVerified,
+		SmsTwoFactor:    user.SmsTwoFactor,
+		IsActive:        user.IsActive,
+		IsVerified:      user.IsVerified,
+		ApprovalPending: user.ApprovalPending,
+		Role:            user.Role,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
+	}
+
+	return NewResponse(c, "success", userDTO, "", http.StatusOK)
+}
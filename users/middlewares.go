@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"slices"
+	"strconv"
 	"time"
+	"users/repository"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
@@ -16,27 +23,63 @@ const (
 	UserRole
 )
 
+type TokenType string
+
+const (
+	AccessToken      TokenType = "access"
+	RefreshToken     TokenType = "refresh"
+	VerifyEmailToken TokenType = "verify_email"
+	TwoFactorToken   TokenType = "two_factor"
+)
+
+var (
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrInvalidAudience  = errors.New("token has invalid audience")
+	ErrSessionRevoked   = errors.New("session has been revoked or has expired")
+)
+
+// JwtCustomClaims carry the role and permissions the user has in the
+// organization the token was issued for
 type JwtCustomClaims struct {
-	UserID      int64    `json:"userId"`
-	Role        int64    `json:"role"`
-	Permissions []string `json:"permissions"`
+	UserID         int64     `json:"userId"`
+	Role           int64     `json:"role"`
+	Permissions    []string  `json:"permissions"`
+	TokenType      TokenType `json:"typ"`
+	SessionID      int32     `json:"sid,omitempty"`
+	OrganizationID int32     `json:"org"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(secretKey string, userId, role, duration int64, permissions []string) (string, error) {
+func GenerateToken(cfg *Config, tokenType TokenType, userId, role int64, organizationID, sessionID int32, permissions []string) (string, error) {
+	return signToken(cfg, tokenType, userId, role, organizationID, sessionID, permissions, uuid.New().String())
+}
+
+// signToken issues a token with id as its jti, refresh tokens get theirs
+// from the session that only accepts the latest one
+func signToken(cfg *Config, tokenType TokenType, userId, role int64, organizationID, sessionID int32, permissions []string, id string) (string, error) {
+	now := time.Now()
 	claims := &JwtCustomClaims{
 		userId,
 		role,
 		permissions,
+		tokenType,
+		sessionID,
+		organizationID,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(duration))),
+			Issuer:    cfg.JWTIssuer,
+			Subject:   strconv.FormatInt(userId, 10),
+			Audience:  cfg.JWTAudience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.TokenTTL(tokenType))),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        id,
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte(secretKey))
+	t, err := token.SignedString([]byte(cfg.JWTSecret))
 	if err != nil {
 		return "", err
 	}
@@ -44,16 +87,68 @@ func GenerateToken(secretKey string, userId, role, duration int64, permissions [
 	return t, nil
 }
 
-func JWTMiddleware(secretKey string) echo.MiddlewareFunc {
+// GenerateOpaqueToken returns a random single use token for email links and
+// the hash that gets stored, hashed like api keys since it is just as random
+func GenerateOpaqueToken() (token, hash string, err error) {
+	tokenBytes := make([]byte, 32)
+	if _, err = rand.Read(tokenBytes); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(tokenBytes)
+	return token, HashApiKey(token), nil
+}
+
+// ParseToken verifies the signature and the standard claims of a token and
+// makes sure it was issued for the expected token type
+func ParseToken(cfg *Config, tokenString string, tokenType TokenType) (*JwtCustomClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(cfg.JWTIssuer),
+		jwt.WithLeeway(cfg.JWTLeeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+
+	claims := new(JwtCustomClaims)
+	_, err := parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+
+	// tokens from before organizations were all issued by the default one
+	if claims.OrganizationID == 0 {
+		claims.OrganizationID = DefaultOrganizationID
+	}
+
+	// the token has to be issued for at least one of our audiences
+	if len(cfg.JWTAudience) > 0 && !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(cfg.JWTAudience, aud)
+	}) {
+		return nil, ErrInvalidAudience
+	}
+
+	return claims, nil
+}
+
+func JWTMiddleware(cfg *Config) echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(JwtCustomClaims)
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			claims, err := ParseToken(cfg, auth, AccessToken)
+			if err != nil {
+				return nil, err
+			}
+			return claims, nil
 		},
-		SigningKey: []byte(secretKey),
 		ContextKey: "token",
 		SuccessHandler: func(c echo.Context) {
-			token := c.Get("token").(*jwt.Token)
-			claims := token.Claims.(*JwtCustomClaims)
+			claims := c.Get("token").(*JwtCustomClaims)
 			c.Set("permissions", claims.Permissions)
 			c.Set("userID", claims.UserID)
 			c.Set("role", claims.Role)
@@ -62,11 +157,72 @@ func JWTMiddleware(secretKey string) echo.MiddlewareFunc {
 
 }
 
+// AuthenticateToken accepts an access token while the session it was issued
+// for is still active
+func AuthenticateToken(ctx context.Context, cfg *Config, repo *repository.Queries, token string) (*JwtCustomClaims, error) {
+	claims, err := ParseToken(cfg, token, AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckSession(ctx, repo, claims.SessionID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Authenticate accepts either an api key in the X-API-Key header or a JWT
+// bearer token and exposes the same context values for both. Tokens are only
+// accepted while the session they were issued for is still active, and only
+// for the organization they were issued for.
+func Authenticate(cfg *Config, repo *repository.Queries) echo.MiddlewareFunc {
+	jwtMiddleware := JWTMiddleware(cfg)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtMiddleware(func(c echo.Context) error {
+			claims := c.Get("token").(*JwtCustomClaims)
+			if err := CheckSession(c.Request().Context(), repo, claims.SessionID); err != nil {
+				return NewResponse(c, "unauthorized", nil, err.Error(), http.StatusUnauthorized)
+			}
+			if err := useCredentialTenant(c, repo, claims.OrganizationID); err != nil {
+				return NewResponse(c, "forbidden", nil, err.Error(), http.StatusForbidden)
+			}
+
+			c.Set("sessionID", claims.SessionID)
+			return next(c)
+		})
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(ApiKeyHeader)
+			if key == "" {
+				return withJWT(c)
+			}
+
+			principal, err := AuthenticateApiKey(c.Request().Context(), repo, key)
+			if err != nil {
+				return NewResponse(c, "unauthorized", nil, err.Error(), http.StatusUnauthorized)
+			}
+			if err := useCredentialTenant(c, repo, principal.OrganizationID); err != nil {
+				return NewResponse(c, "forbidden", nil, err.Error(), http.StatusForbidden)
+			}
+
+			c.Set("apiKey", principal)
+			c.Set("permissions", principal.Permissions)
+			c.Set("userID", principal.UserID)
+			c.Set("role", principal.Role)
+			return next(c)
+		}
+	}
+}
+
+// HasPermission is the authorization check shared by the http and grpc servers
+func HasPermission(permissions []string, permission string) bool {
+	return slices.Contains(permissions, permission)
+}
+
 func Has(permission string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			permissions, ok := c.Get("permissions").([]string)
-			if !ok || !slices.Contains(permissions, permission) {
+			permissions, _ := c.Get("permissions").([]string)
+			if !HasPermission(permissions, permission) {
 				err := "invalid permissions"
 				return NewResponse(c, "forbidden", nil, err, http.StatusForbidden)
 			}
@@ -74,4 +230,4 @@ func Has(permission string) echo.MiddlewareFunc {
 			return next(c)
 		}
 	}
-}
\ No newline at end of file
+}
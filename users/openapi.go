@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	OpenAPIVersion = "3.1.0"
+	APIVersion     = "1.0.0"
+)
+
+// RouteDoc describes a route for the OpenAPI document. Body and Data are zero
+// values of the request body and of the data field of the success response.
+type RouteDoc struct {
+	Summary     string
+	Query       []string
+	Body        any
+	BodyContent string
+	Data        any
+	Status      int
+	Content     string
+}
+
+// routeDocs is keyed by method and path as registered, e.g. "GET /users/:id".
+// The openapi test fails for routes without an entry.
+var routeDocs = map[string]RouteDoc{
+	"GET /health":                              {Summary: "Health check", Data: ""},
+	"GET /openapi.json":                        {Summary: "This OpenAPI document", Content: echo.MIMEApplicationJSON},
+	"GET /events/schemas":                      {Summary: "JSON schemas of every version of every published event", Data: []EventSchemaDTO{}},
+	"GET /verify-email":                        {Summary: "Verify the email of a registered user", Query: []string{"token"}},
+	"POST /register":                           {Summary: "Register a new user, subject to the registration policy", Body: RegisterDTO{}, Data: UserGetDTO{}},
+	"POST /login":                              {Summary: "Log in, answers 202 with a challenge when an sms code is required", Body: LoginDTO{}, Data: LoginResponseDTO{}},
+	"POST /login/verify":                       {Summary: "Complete a login with the sms code", Body: VerifyLoginDTO{}, Data: LoginResponseDTO{}},
+	"POST /forgot-password":                    {Summary: "Email a password reset link", Body: ForgotPasswordDTO{}},
+	"POST /reset-password":                     {Summary: "Set a new password with a reset token", Body: ResetPasswordDTO{}},
+	"POST /refresh-token":                      {Summary: "Exchange a refresh token for an access token and a new refresh token", Body: RefreshTokenDTO{}, Data: AccessTokenDTO{}},
+	"POST /invitations/accept":                 {Summary: "Create the invited account", Body: AcceptInvitationDTO{}, Data: UserGetDTO{}},
+	"POST /authorize":                          {Summary: "Decide whether a token, the caller or a subject holds a permission or may call a route, usable as a forward-auth hook", Query: []string{"permission"}, Body: AuthorizeDTO{}, Data: AuthorizeDecisionDTO{}},
+	"POST /authorize/batch":                    {Summary: "Decide several checks for one token or subject", Body: AuthorizeBatchDTO{}, Data: AuthorizeBatchDecisionDTO{}},
+	"GET /email-change/confirm":                {Summary: "Confirm a pending email change", Query: []string{"token"}},
+	"GET /email-change/cancel":                 {Summary: "Cancel a pending email change", Query: []string{"token"}},
+	"GET /logout":                              {Summary: "Revoke the current session"},
+	"GET /me/export":                           {Summary: "Export all data held about the caller", Data: UserExportDTO{}},
+	"GET /me/email":                            {Summary: "Pending email change of the caller", Data: EmailChangeDTO{}},
+	"POST /me/email":                           {Summary: "Request a change of the caller's email", Body: ChangeEmailDTO{}, Data: EmailChangeDTO{}, Status: http.StatusAccepted},
+	"POST /me/phone/verify":                    {Summary: "Text a verification code to the caller's phone", Status: http.StatusAccepted},
+	"POST /me/phone/confirm":                   {Summary: "Verify the caller's phone with the texted code", Body: VerifyPhoneDTO{}},
+	"PUT /me/two-factor":                       {Summary: "Turn the sms second factor on or off", Body: SmsTwoFactorDTO{}},
+	"GET /me/sessions":                         {Summary: "Active sessions of the caller", Data: []SessionDTO{}},
+	"DELETE /me/sessions/:id":                  {Summary: "Revoke one of the caller's sessions"},
+	"GET /users/:id/sessions":                  {Summary: "Active sessions of a user", Data: []SessionDTO{}},
+	"DELETE /users/:id/sessions":               {Summary: "Revoke all sessions of a user"},
+	"DELETE /users/:id/sessions/:sessionId":    {Summary: "Revoke a session of a user"},
+	"GET /api-keys":                            {Summary: "Api keys of the caller, all keys with all=true", Query: []string{"all"}, Data: []ApiKeyDTO{}},
+	"POST /api-keys":                           {Summary: "Create an api key, the key is only returned once", Body: CreateApiKeyDTO{}, Data: CreatedApiKeyDTO{}, Status: http.StatusAccepted},
+	"DELETE /api-keys/:id":                     {Summary: "Revoke an api key"},
+	"GET /invitations":                         {Summary: "List invitations, only open ones with pending=true", Query: []string{"pending"}, Data: []InvitationDTO{}},
+	"POST /invitations":                        {Summary: "Invite a user by email", Body: CreateInvitationDTO{}, Data: InvitationDTO{}, Status: http.StatusAccepted},
+	"POST /invitations/:id/resend":             {Summary: "Send an invitation again with a new link", Data: InvitationDTO{}},
+	"DELETE /invitations/:id":                  {Summary: "Revoke an open invitation"},
+	"GET /webhooks":                            {Summary: "List webhook subscriptions", Data: []WebhookSubscriptionDTO{}},
+	"GET /webhooks/:id":                        {Summary: "Get a webhook subscription", Data: WebhookSubscriptionDTO{}},
+	"POST /webhooks":                           {Summary: "Subscribe a url to events, the signing secret is only shown here", Body: WebhookDTO{}, Data: CreatedWebhookDTO{}, Status: http.StatusAccepted},
+	"PUT /webhooks/:id":                        {Summary: "Update a webhook subscription", Body: WebhookDTO{}, Data: WebhookSubscriptionDTO{}, Status: http.StatusAccepted},
+	"POST /webhooks/:id/secret":                {Summary: "Rotate the signing secret of a webhook", Data: CreatedWebhookDTO{}, Status: http.StatusAccepted},
+	"DELETE /webhooks/:id":                     {Summary: "Delete a webhook subscription with its delivery log"},
+	"GET /webhooks/:id/deliveries":             {Summary: "List the latest deliveries of a webhook", Query: []string{"limit"}, Data: []WebhookDeliveryDTO{}},
+	"GET /webhooks/:id/deliveries/:deliveryId": {Summary: "Get a delivery with its payload and every attempt", Data: WebhookDeliveryLogDTO{}},
+	"POST /webhooks/:id/deliveries/:deliveryId/redeliver": {Summary: "Queue a delivery again", Status: http.StatusAccepted},
+	"GET /permissions":                          {Summary: "List permissions", Query: []string{"deleted", "group"}, Data: []repository.Permission{}},
+	"GET /permissions/routes":                   {Summary: "Routes unlocked by each permission", Data: []PermissionRoutes{}},
+	"POST /permissions":                         {Summary: "Create permissions", Body: PermissionsDTO{}, Data: PermissionsDTO{}, Status: http.StatusAccepted},
+	"DELETE /permissions/:id":                   {Summary: "Soft delete a permission"},
+	"POST /permissions/:id/restore":             {Summary: "Restore a soft deleted permission"},
+	"GET /roles":                                {Summary: "List roles", Query: []string{"deleted"}, Data: []repository.Role{}},
+	"GET /roles/:id":                            {Summary: "Get a role", Data: repository.Role{}},
+	"POST /roles":                               {Summary: "Create a role", Body: RoleDTO{}, Data: repository.Role{}, Status: http.StatusAccepted},
+	"PUT /roles/:id":                            {Summary: "Update a role", Body: RoleDTO{}, Status: http.StatusAccepted},
+	"DELETE /roles/:id":                         {Summary: "Soft delete a role"},
+	"POST /roles/:id/restore":                   {Summary: "Restore a soft deleted role"},
+	"GET /users":                                {Summary: "List users", Query: []string{"deleted"}, Data: []UserGetDTO{}},
+	"GET /users/:id":                            {Summary: "Get a user", Data: UserGetDTO{}},
+	"POST /users":                               {Summary: "Create a user", Body: CreateUserDTO{}, Data: UserGetDTO{}, Status: http.StatusAccepted},
+	"PUT /users/:id":                            {Summary: "Update a user", Body: UpdateUserDTO{}, Status: http.StatusAccepted},
+	"DELETE /users/:id":                         {Summary: "Soft delete a user"},
+	"POST /users/import":                        {Summary: "Import users from csv or ndjson", Query: []string{"format", "dry_run", "invite"}, BodyContent: "text/csv", Data: ImportReport{}},
+	"GET /users/export":                         {Summary: "Export users as csv or ndjson", Query: []string{"format", "deleted"}, Content: "text/csv"},
+	"GET /users/pending-approval":               {Summary: "Self registered users waiting for approval", Data: []UserGetDTO{}},
+	"POST /users/:id/approve":                   {Summary: "Approve a self registered user"},
+	"POST /users/:id/reject":                    {Summary: "Reject a self registered user"},
+	"POST /users/:id/restore":                   {Summary: "Restore a soft deleted user"},
+	"POST /users/:id/email":                     {Summary: "Request a change of a user's email", Body: AdminChangeEmailDTO{}, Data: EmailChangeDTO{}, Status: http.StatusAccepted},
+	"GET /users/:id/export":                     {Summary: "Export all data held about a user", Data: UserExportDTO{}},
+	"POST /users/:id/erase":                     {Summary: "Erase the personal data of a user"},
+	"GET /me/groups":                            {Summary: "Groups of the caller in the organization", Data: []GroupGetDTO{}},
+	"GET /users/:id/groups":                     {Summary: "Groups of a user", Data: []GroupGetDTO{}},
+	"GET /groups":                               {Summary: "List groups", Data: []GroupGetDTO{}},
+	"GET /groups/:id":                           {Summary: "Get a group with the roles it grants", Data: GroupGetDTO{}},
+	"POST /groups":                              {Summary: "Create a group", Body: GroupDTO{}, Data: GroupGetDTO{}, Status: http.StatusCreated},
+	"PUT /groups/:id":                           {Summary: "Rename a group", Body: GroupDTO{}, Data: GroupGetDTO{}},
+	"DELETE /groups/:id":                        {Summary: "Delete a group with its memberships and grants"},
+	"GET /groups/:id/members":                   {Summary: "Members of a group", Data: []GroupMemberGetDTO{}},
+	"PUT /groups/:id/members/:userId":           {Summary: "Add a user to a group"},
+	"DELETE /groups/:id/members/:userId":        {Summary: "Remove a user from a group"},
+	"PUT /groups/:id/roles/:roleId":             {Summary: "Grant a role to every member of a group"},
+	"DELETE /groups/:id/roles/:roleId":          {Summary: "Revoke a role from a group"},
+	"GET /me/organizations":                     {Summary: "Organizations the caller belongs to or is a member of", Data: []OrganizationGetDTO{}},
+	"POST /me/organizations":                    {Summary: "Join the organization that invited the caller's address", Body: JoinOrganizationDTO{}, Data: OrganizationMemberGetDTO{}},
+	"GET /organizations":                        {Summary: "List organizations", Data: []OrganizationGetDTO{}},
+	"GET /organizations/:id":                    {Summary: "Get an organization", Data: OrganizationGetDTO{}},
+	"POST /organizations":                       {Summary: "Create an organization with the manifest roles, inviting its first admin", Body: OrganizationDTO{}, Data: OrganizationGetDTO{}, Status: http.StatusCreated},
+	"PUT /organizations/:id":                    {Summary: "Rename, reslug or (de)activate an organization", Body: OrganizationDTO{}, Data: OrganizationGetDTO{}},
+	"GET /organizations/:id/members":            {Summary: "Members of an organization from other organizations", Data: []OrganizationMemberGetDTO{}},
+	"PUT /organizations/:id/members/:userId":    {Summary: "Add a user of another organization as a member or change their role", Body: OrganizationMemberDTO{}, Data: OrganizationMemberGetDTO{}},
+	"DELETE /organizations/:id/members/:userId": {Summary: "Remove a member from an organization"},
+}
+
+// OpenAPI describes every registered route, the response envelope and the
+// security schemes as an OpenAPI 3.1 document
+func (r *RouteRegistry) OpenAPI() map[string]any {
+	schemas := newSchemaBuilder()
+	schemas.components["FieldError"] = schemas.structSchema(reflect.TypeOf(FieldError{}))
+	schemas.components["Response"] = map[string]any{
+		"type":        "object",
+		"description": "Envelope of every json response, error_code is stable and meant for programs",
+		"properties": map[string]any{
+			"message":    map[string]any{"type": "string"},
+			"data":       map[string]any{},
+			"error":      map[string]any{"type": "string"},
+			"error_code": map[string]any{"type": "string"},
+			"errors":     map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/FieldError"}},
+			"code":       map[string]any{"type": "integer"},
+		},
+		"required": []string{"message", "data", "code"},
+	}
+
+	paths := make(map[string]any)
+	for _, route := range r.routes {
+		path := openAPIPath(r.prefix + route.Path)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = r.operation(route, schemas)
+	}
+
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":       "Users Auth",
+			"version":     APIVersion,
+			"description": "Authentication, users, roles and permissions",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": map[string]any{"type": "apiKey", "in": "header", "name": ApiKeyHeader},
+			},
+		},
+	}
+}
+
+func (r *RouteRegistry) operation(route Route, schemas *schemaBuilder) map[string]any {
+	doc := routeDocs[route.Method+" "+route.Path]
+	op := map[string]any{
+		"operationId": handlerName(route.Handler),
+		"summary":     doc.Summary,
+		"tags":        []string{openAPITag(route.Path)},
+	}
+
+	parameters := make([]any, 0)
+	for _, segment := range strings.Split(route.Path, "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			parameters = append(parameters, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "integer", "format": "int32"},
+			})
+		}
+	}
+	for _, name := range doc.Query {
+		parameters = append(parameters, map[string]any{
+			"name": name, "in": "query",
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	parameters = append(parameters, map[string]any{
+		"name": TenantHeader, "in": "header",
+		"description": "Slug of the organization, defaults to the one of the credentials",
+		"schema":      map[string]any{"type": "string"},
+	})
+	op["parameters"] = parameters
+
+	switch {
+	case doc.Body != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{echo.MIMEApplicationJSON: map[string]any{"schema": schemas.schema(reflect.TypeOf(doc.Body))}},
+		}
+	case doc.BodyContent != "":
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{doc.BodyContent: map[string]any{"schema": map[string]any{"type": "string"}}},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var success map[string]any
+	switch {
+	case doc.Content == "text/csv":
+		success = map[string]any{
+			"description": "Rows as csv, or ndjson with format=ndjson",
+			"content": map[string]any{
+				"text/csv":             map[string]any{"schema": map[string]any{"type": "string"}},
+				"application/x-ndjson": map[string]any{"schema": map[string]any{"type": "string"}},
+			},
+		}
+	case doc.Content != "":
+		success = map[string]any{
+			"description": http.StatusText(status),
+			"content":     map[string]any{doc.Content: map[string]any{"schema": map[string]any{"type": "object"}}},
+		}
+	default:
+		envelope := map[string]any{"$ref": "#/components/schemas/Response"}
+		if doc.Data != nil {
+			envelope = map[string]any{"allOf": []any{
+				envelope,
+				map[string]any{"properties": map[string]any{"data": schemas.schema(reflect.TypeOf(doc.Data))}},
+			}}
+		}
+		success = map[string]any{
+			"description": http.StatusText(status),
+			"content":     map[string]any{echo.MIMEApplicationJSON: map[string]any{"schema": envelope}},
+		}
+	}
+
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{echo.MIMEApplicationJSON: map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Response"}}},
+		}
+	}
+	responses := map[string]any{
+		fmt.Sprint(status): success,
+		"default":          errorResponse("Error, error_code tells the kind"),
+	}
+
+	if route.Public {
+		op["security"] = []any{}
+	} else {
+		op["security"] = []any{
+			map[string]any{"bearerAuth": []string{}},
+			map[string]any{"apiKeyAuth": []string{}},
+		}
+		responses["401"] = errorResponse("Missing or invalid credentials")
+	}
+	if route.Permission != "" {
+		op["x-required-permission"] = route.Permission
+		op["description"] = fmt.Sprintf("Requires the `%s` permission.", route.Permission)
+		responses["403"] = errorResponse("Missing the required permission")
+	}
+	op["responses"] = responses
+	return op
+}
+
+// openAPIPath turns echo path params into OpenAPI ones, /users/:id becomes /users/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// openAPITag groups operations by the first path segment, e.g. "users"
+func openAPITag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return segment
+}
+
+// handlerName is the method name of a handler, used as operation id
+func handlerName(handler echo.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	pgTextType    = reflect.TypeOf(pgtype.Text{})
+	pgBoolType    = reflect.TypeOf(pgtype.Bool{})
+	pgInt4Type    = reflect.TypeOf(pgtype.Int4{})
+	pgInt8Type    = reflect.TypeOf(pgtype.Int8{})
+	pgTimeType    = reflect.TypeOf(pgtype.Timestamp{})
+	pgTimeTzType  = reflect.TypeOf(pgtype.Timestamptz{})
+	pgNullSchemas = map[reflect.Type]map[string]any{
+		pgTextType:   {"type": []string{"string", "null"}},
+		pgBoolType:   {"type": []string{"boolean", "null"}},
+		pgInt4Type:   {"type": []string{"integer", "null"}, "format": "int32"},
+		pgInt8Type:   {"type": []string{"integer", "null"}, "format": "int64"},
+		pgTimeType:   {"type": []string{"string", "null"}, "format": "date-time"},
+		pgTimeTzType: {"type": []string{"string", "null"}, "format": "date-time"},
+	}
+)
+
+// schemaBuilder derives json schemas from go types, named structs end up in
+// components and are referenced
+type schemaBuilder struct {
+	components map[string]any
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: make(map[string]any)}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if schema, ok := pgNullSchemas[t]; ok {
+		return schema
+	}
+
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// reserve the name first so recursive types terminate
+			b.components[t.Name()] = map[string]any{}
+			b.components[t.Name()] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.schema(field.Type)
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		if _, isRef := property["$ref"]; !isRef {
+			property = withValidation(property, field.Type, rules)
+		}
+		properties[name] = property
+
+		for _, rule := range rules {
+			if rule == "required" {
+				required = append(required, name)
+			}
+			if rule == "dive" {
+				break
+			}
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// withValidation adds the constraints of validate rules that json schema can
+// express, rules after dive apply to items and are left out
+func withValidation(property map[string]any, t reflect.Type, rules []string) map[string]any {
+	result := make(map[string]any, len(property))
+	for k, v := range property {
+		result[k] = v
+	}
+
+	for _, rule := range rules {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "dive":
+			return result
+		case "email":
+			result["format"] = "email"
+		case "url":
+			result["format"] = "uri"
+		case "e164":
+			result["pattern"] = e164Pattern.String()
+		case "oneof":
+			result["enum"] = strings.Fields(param)
+		case "min", "max", "len":
+			var n int
+			if _, err := fmt.Sscan(param, &n); err != nil {
+				continue
+			}
+			for _, key := range sizeKeywords(t.Kind(), tag) {
+				result[key] = n
+			}
+		case "gt":
+			var n int
+			if _, err := fmt.Sscan(param, &n); err == nil {
+				result["exclusiveMinimum"] = n
+			}
+		}
+	}
+	return result
+}
+
+func sizeKeywords(kind reflect.Kind, tag string) []string {
+	var minKey, maxKey string
+	switch kind {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+
+	switch tag {
+	case "min":
+		return []string{minKey}
+	case "max":
+		return []string{maxKey}
+	}
+	return []string{minKey, maxKey}
+}
+
+func (h *AuthHandler) GetOpenAPI(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Routes.OpenAPI())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestServer sets up the real router without a database, enough for
+// routing and requests that fail before touching it
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := LoadConfig()
+	cfg.SmsProvider = SmsProviderMemory
+	cfg.JWTSecret = "test-secret"
+
+	passwords, err := NewPasswordPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		Echo:      echo.New(),
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Cfg:       cfg,
+		Ctx:       context.Background(),
+		Passwords: passwords,
+	}
+	if err := s.SetupRouter(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	s.Echo.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET openapi.json: status %d", rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.1") {
+		t.Errorf("openapi version %q, want 3.1", spec.OpenAPI)
+	}
+
+	routes := s.Echo.Routes()
+	if len(routes) == 0 {
+		t.Fatal("no routes registered")
+	}
+	for _, route := range routes {
+		operation, ok := spec.Paths[openAPIPath(route.Path)][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("route %s %s is missing from the spec", route.Method, route.Path)
+			continue
+		}
+		if summary, _ := operation["summary"].(string); summary == "" {
+			t.Errorf("route %s %s has no entry in routeDocs", route.Method, route.Path)
+		}
+	}
+}
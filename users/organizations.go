@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultOrganizationID is the organization created by the migration, it
+	// owns everything from before organizations and hosts the platform
+	// administrators who manage the other ones
+	DefaultOrganizationID int32 = 1
+
+	// TenantHeader names the organization of a request by its slug
+	TenantHeader = "X-Tenant"
+)
+
+var (
+	ErrTenantMismatch       = errors.New("credentials were issued for another organization")
+	ErrOrganizationInactive = errors.New("organization is deactivated")
+)
+
+// slugs are used as subdomains, so they follow the rules of a dns label
+var slugRE = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// tenantID is the organization the request is served for, every repository
+// call of a handler is scoped to it
+func tenantID(c echo.Context) int32 {
+	if id, ok := c.Get("organizationID").(int32); ok {
+		return id
+	}
+	return DefaultOrganizationID
+}
+
+// tenantSlug is the organization a request names, by the X-Tenant header or
+// by being sent to <slug>.<tenant domain>
+func tenantSlug(cfg *Config, r *http.Request) string {
+	if slug := r.Header.Get(TenantHeader); slug != "" {
+		return strings.ToLower(slug)
+	}
+	if cfg.TenantDomain == "" {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	slug, ok := strings.CutSuffix(strings.ToLower(host), "."+cfg.TenantDomain)
+	if !ok || strings.Contains(slug, ".") {
+		return ""
+	}
+	return slug
+}
+
+// ResolveTenant picks the organization of a request from the X-Tenant header
+// or the subdomain. Requests naming none are served for the organization
+// their credentials were issued for, see Authenticate, or the default one.
+func ResolveTenant(cfg *Config, repo *repository.Queries) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			slug := tenantSlug(cfg, c.Request())
+			if slug == "" {
+				c.Set("organizationID", DefaultOrganizationID)
+				return next(c)
+			}
+
+			org, err := repo.GetOrganizationBySlug(c.Request().Context(), slug)
+			if err != nil {
+				return DBError(err, "organization")
+			}
+			if !org.IsActive {
+				return ForbiddenError(ErrOrganizationInactive.Error())
+			}
+
+			c.Set("organizationID", org.ID)
+			c.Set("tenantExplicit", true)
+			return next(c)
+		}
+	}
+}
+
+// useCredentialTenant serves the request for the organization credentials
+// were issued for. A request that named another organization is refused, so
+// a token of one tenant never reads another one's records.
+func useCredentialTenant(c echo.Context, repo *repository.Queries, organizationID int32) error {
+	if explicit, _ := c.Get("tenantExplicit").(bool); explicit {
+		if tenantID(c) != organizationID {
+			return ErrTenantMismatch
+		}
+		return nil
+	}
+
+	// the default organization cannot be deactivated
+	if organizationID != DefaultOrganizationID {
+		org, err := repo.GetOrganization(c.Request().Context(), organizationID)
+		if err != nil || !org.IsActive {
+			return ErrOrganizationInactive
+		}
+	}
+	c.Set("organizationID", organizationID)
+	return nil
+}
+
+// withTenantRole gives a member from another organization the role they
+// have in organizationID, users of the organization keep their own
+func withTenantRole(ctx context.Context, repo *repository.Queries, user repository.User, organizationID int32) (repository.User, error) {
+	if user.OrganizationID == organizationID {
+		return user, nil
+	}
+
+	member, err := repo.GetOrganizationMember(ctx, repository.GetOrganizationMemberParams{
+		OrganizationID: organizationID,
+		UserID:         user.ID,
+	})
+	if err != nil {
+		return user, err
+	}
+	user.Role = member.Role
+	return user, nil
+}
+
+// tenantUser loads a user who may act in the organization, one of its own or
+// a member, with Role set to their role in it. The role must not be written
+// back to the user, it is only theirs in organizationID.
+func tenantUser(ctx context.Context, repo *repository.Queries, userID int64, organizationID int32) (repository.User, error) {
+	user, err := repo.GetTenantUser(ctx, repository.GetTenantUserParams{
+		ID:             int32(userID),
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return user, err
+	}
+	return withTenantRole(ctx, repo, user, organizationID)
+}
+
+// requireTenantUser answers 404 for a user of another organization, for
+// records that are keyed by the user alone
+func (h *AuthHandler) requireTenantUser(c echo.Context, id int32) error {
+	if _, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: id, OrganizationID: tenantID(c)}); err != nil {
+		return DBError(err, "user")
+	}
+	return nil
+}
+
+// platformTenant allows managing organizations only from the default one,
+// roles of other organizations cannot grant it whatever their permissions
+func platformTenant(c echo.Context) error {
+	if tenantID(c) != DefaultOrganizationID {
+		return ForbiddenError("organizations are managed from the default organization")
+	}
+	return nil
+}
+
+// organizationParam is the organization of the path, an organization can see
+// and manage its own members, the default one every organization's
+func organizationParam(c echo.Context) (int32, error) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if tenant := tenantID(c); tenant != DefaultOrganizationID && tenant != int32(id) {
+		return 0, NotFoundError("organization not found")
+	}
+	return int32(id), nil
+}
+
+// validateOrganization requires a slug that works as a subdomain
+func validateOrganization(data *OrganizationDTO) ValidationErrors {
+	if slugRE.MatchString(data.Slug) {
+		return nil
+	}
+	return ValidationErrors{{
+		Field:   "slug",
+		Rule:    "slug",
+		Message: "must be lowercase letters, digits and dashes, not starting or ending with a dash",
+	}}
+}
+
+// seedOrganizationRoles creates the roles of the bootstrap manifest in a new
+// organization and returns its superadmin role
+func seedOrganizationRoles(ctx context.Context, qtx *repository.Queries, cfg *Config, organizationID int32) (repository.Role, error) {
+	manifest, err := LoadManifest(cfg.BootstrapManifest, KnownPermissions)
+	if err != nil {
+		return repository.Role{}, err
+	}
+	roles, err := upsertManifestRoles(ctx, qtx, manifest, organizationID)
+	if err != nil {
+		return repository.Role{}, err
+	}
+	return roles[manifest.SuperAdminRole], nil
+}
+
+func newOrganizationDTO(org repository.Organization) OrganizationGetDTO {
+	return OrganizationGetDTO{
+		ID:                org.ID,
+		Name:              org.Name,
+		Slug:              org.Slug,
+		IsActive:          org.IsActive,
+		AllowRegistration: org.AllowRegistration,
+		CreatedAt:         org.CreatedAt,
+		UpdatedAt:         org.UpdatedAt,
+	}
+}
+
+func newOrganizationsDTO(orgs []repository.Organization) []OrganizationGetDTO {
+	orgsDTO := make([]OrganizationGetDTO, 0)
+	for _, org := range orgs {
+		orgsDTO = append(orgsDTO, newOrganizationDTO(org))
+	}
+	return orgsDTO
+}
+
+func newOrganizationMemberDTO(member repository.OrganizationMember) OrganizationMemberGetDTO {
+	return OrganizationMemberGetDTO{
+		OrganizationID: member.OrganizationID,
+		UserID:         member.UserID,
+		Role:           member.Role,
+		CreatedAt:      member.CreatedAt,
+		UpdatedAt:      member.UpdatedAt,
+	}
+}
+
+// organizations handlers
+
+func (h *AuthHandler) ListOrganizations(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
+	orgs, err := h.Repo.ListOrganizations(h.Ctx)
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	return NewResponse(c, "success", newOrganizationsDTO(orgs), "", http.StatusOK)
+}
+
+func (h *AuthHandler) GetOrganization(c echo.Context) error {
+	id, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+
+	org, err := h.Repo.GetOrganization(h.Ctx, id)
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	return NewResponse(c, "success", newOrganizationDTO(org), "", http.StatusOK)
+}
+
+// CreateOrganization creates an organization with the roles of the bootstrap
+// manifest. The admin email is invited with the superadmin role, the first
+// administrator then manages the organization like the default one.
+func (h *AuthHandler) CreateOrganization(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
+	data := new(OrganizationDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+	if fields := validateOrganization(data); fields != nil {
+		return ValidationError(fields)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	org, err := qtx.CreateOrganization(h.Ctx, repository.CreateOrganizationParams{
+		Name:              data.Name,
+		Slug:              data.Slug,
+		IsActive:          data.IsActive == nil || *data.IsActive,
+		AllowRegistration: data.AllowRegistration != nil && *data.AllowRegistration,
+	})
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	superAdmin, err := seedOrganizationRoles(h.Ctx, qtx, h.Cfg, org.ID)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	var invitation repository.Invitation
+	token, hash := "", ""
+	if data.AdminEmail != "" {
+		if token, hash, err = GenerateOpaqueToken(); err != nil {
+			return InternalError(err)
+		}
+
+		userID, _ := c.Get("userID").(int64)
+		invitation, err = qtx.CreateInvitation(h.Ctx, repository.CreateInvitationParams{
+			OrganizationID: org.ID,
+			Email:          data.AdminEmail,
+			Role:           int64(superAdmin.ID),
+			TokenHash:      hash,
+			InvitedBy:      pgtype.Int4{Int32: int32(userID), Valid: userID != 0},
+			ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(h.Cfg.InvitationTTL), Valid: true},
+		})
+		if err != nil {
+			return DBError(err, "invitation")
+		}
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	if token != "" {
+		if err = h.sendInvitation(invitation, token); err != nil {
+			return InternalError(err)
+		}
+	}
+
+	return NewResponse(c, "success", newOrganizationDTO(org), "", http.StatusCreated)
+}
+
+func (h *AuthHandler) UpdateOrganization(c echo.Context) error {
+	if err := platformTenant(c); err != nil {
+		return err
+	}
+
+	data := new(OrganizationDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+	if fields := validateOrganization(data); fields != nil {
+		return ValidationError(fields)
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	org, err := h.Repo.GetOrganization(h.Ctx, int32(id))
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	isActive := org.IsActive
+	if data.IsActive != nil {
+		isActive = *data.IsActive
+	}
+	if org.ID == DefaultOrganizationID && !isActive {
+		return ForbiddenError("the default organization cannot be deactivated")
+	}
+
+	allowRegistration := org.AllowRegistration
+	if data.AllowRegistration != nil {
+		allowRegistration = *data.AllowRegistration
+	}
+
+	org, err = h.Repo.UpdateOrganization(h.Ctx, repository.UpdateOrganizationParams{
+		ID:                org.ID,
+		Name:              data.Name,
+		Slug:              data.Slug,
+		IsActive:          isActive,
+		AllowRegistration: allowRegistration,
+	})
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	return NewResponse(c, "success", newOrganizationDTO(org), "", http.StatusOK)
+}
+
+// ListMyOrganizations lists the organizations the caller can sign in to,
+// their own and the ones they are a member of
+func (h *AuthHandler) ListMyOrganizations(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	orgs, err := h.Repo.ListUserOrganizations(h.Ctx, int32(userID))
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	return NewResponse(c, "success", newOrganizationsDTO(orgs), "", http.StatusOK)
+}
+
+// JoinOrganization makes the caller a member of the organization that invited
+// their address, with the role of the invitation
+func (h *AuthHandler) JoinOrganization(c echo.Context) error {
+	data := new(JoinOrganizationDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	invitation, err := h.Repo.GetPendingInvitationByTokenHash(h.Ctx, HashApiKey(data.Token))
+	if err != nil || !strings.EqualFold(invitation.Email, user.Email) {
+		return NewResponse(c, "failed", nil, "invalid or expired invitation", http.StatusBadRequest)
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	accepted, err := qtx.AcceptInvitation(h.Ctx, invitation.ID)
+	if err != nil {
+		return DBError(err, "invitation")
+	}
+	if accepted == 0 {
+		return NewResponse(c, "failed", nil, "invalid or expired invitation", http.StatusBadRequest)
+	}
+
+	member, err := qtx.UpsertOrganizationMember(h.Ctx, repository.UpsertOrganizationMemberParams{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         user.ID,
+		Role:           invitation.Role,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NewResponse(c, "failed", nil, "you already belong to the organization", http.StatusConflict)
+	}
+	if err != nil {
+		return DBError(err, "member")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", newOrganizationMemberDTO(member), "", http.StatusOK)
+}
+
+func (h *AuthHandler) ListOrganizationMembers(c echo.Context) error {
+	id, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+
+	members, err := h.Repo.ListOrganizationMembers(h.Ctx, id)
+	if err != nil {
+		return DBError(err, "member")
+	}
+
+	membersDTO := make([]OrganizationMemberGetDTO, 0)
+	for _, member := range members {
+		membersDTO = append(membersDTO, newOrganizationMemberDTO(member))
+	}
+
+	return NewResponse(c, "success", membersDTO, "", http.StatusOK)
+}
+
+// PutOrganizationMember gives a member another role, the role has to be one
+// of the organization's. Only the default organization can make a user of
+// another organization a member, the others invite them and the user joins.
+func (h *AuthHandler) PutOrganizationMember(c echo.Context) error {
+	id, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+
+	data := new(OrganizationMemberDTO)
+	if err = c.Bind(data); err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	org, err := h.Repo.GetOrganization(h.Ctx, id)
+	if err != nil {
+		return DBError(err, "organization")
+	}
+
+	if _, err = h.Repo.GetRole(h.Ctx, repository.GetRoleParams{ID: int32(data.Role), OrganizationID: org.ID}); err != nil {
+		return DBError(err, "role")
+	}
+
+	// users of the organization itself have their role on their account
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	var member repository.OrganizationMember
+	if tenantID(c) == DefaultOrganizationID {
+		member, err = h.Repo.UpsertOrganizationMember(h.Ctx, repository.UpsertOrganizationMemberParams{
+			OrganizationID: org.ID,
+			UserID:         int32(userID),
+			Role:           data.Role,
+		})
+	} else {
+		member, err = h.Repo.UpdateOrganizationMemberRole(h.Ctx, repository.UpdateOrganizationMemberRoleParams{
+			OrganizationID: org.ID,
+			UserID:         int32(userID),
+			Role:           data.Role,
+		})
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NotFoundError("member not found")
+	}
+	if err != nil {
+		return DBError(err, "member")
+	}
+
+	return NewResponse(c, "success", newOrganizationMemberDTO(member), "", http.StatusOK)
+}
+
+// DeleteOrganizationMember also takes the member out of the organization's
+// groups, so joining again grants nothing from before, and ends their
+// sessions in the organization
+func (h *AuthHandler) DeleteOrganizationMember(c echo.Context) error {
+	id, err := organizationParam(c)
+	if err != nil {
+		return err
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	userID, _ := strconv.Atoi(c.Param("userId"))
+	deleted, err := qtx.DeleteOrganizationMember(h.Ctx, repository.DeleteOrganizationMemberParams{
+		OrganizationID: id,
+		UserID:         int32(userID),
+	})
+	if err != nil {
+		return DBError(err, "member")
+	}
+	if deleted == 0 {
+		return NotFoundError("member not found")
+	}
+
+	err = qtx.RemoveOrganizationGroupMember(h.Ctx, repository.RemoveOrganizationGroupMemberParams{
+		UserID:         int32(userID),
+		OrganizationID: id,
+	})
+	if err != nil {
+		return DBError(err, "group member")
+	}
+
+	err = qtx.RevokeUserOrganizationSessions(h.Ctx, repository.RevokeUserOrganizationSessionsParams{
+		UserID:         int32(userID),
+		OrganizationID: id,
+	})
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
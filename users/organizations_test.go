@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestTenantSlug(t *testing.T) {
+	cfg := &Config{TenantDomain: "example.com"}
+
+	tests := []struct {
+		host, header string
+		want         string
+	}{
+		{"acme.example.com", "", "acme"},
+		{"ACME.example.com:8000", "", "acme"},
+		{"acme.example.com", "Other", "other"},
+		{"example.com", "", ""},
+		{"a.b.example.com", "", ""},
+		{"acme.example.org", "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth/health", nil)
+		req.Host = tt.host
+		if tt.header != "" {
+			req.Header.Set(TenantHeader, tt.header)
+		}
+		if got := tenantSlug(cfg, req); got != tt.want {
+			t.Errorf("tenantSlug(%s, %q) = %q, want %q", tt.host, tt.header, got, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/health", nil)
+	req.Host = "acme.example.com"
+	if got := tenantSlug(&Config{}, req); got != "" {
+		t.Errorf("without a tenant domain the host names no tenant, got %q", got)
+	}
+}
+
+func TestUseCredentialTenant(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Set("organizationID", int32(2))
+	c.Set("tenantExplicit", true)
+
+	if err := useCredentialTenant(c, nil, 3); !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("credentials of another organization: err = %v", err)
+	}
+	if err := useCredentialTenant(c, nil, 2); err != nil {
+		t.Fatalf("credentials of the named organization: err = %v", err)
+	}
+
+	c.Set("organizationID", nil)
+	c.Set("tenantExplicit", false)
+	if err := useCredentialTenant(c, nil, DefaultOrganizationID); err != nil || tenantID(c) != DefaultOrganizationID {
+		t.Fatalf("tenant = %d, err = %v", tenantID(c), err)
+	}
+}
+
+func TestValidateOrganization(t *testing.T) {
+	for slug, valid := range map[string]bool{"acme": true, "acme-2": true, "-acme": false, "acme-": false, "Acme": false, "a.b": false} {
+		if got := validateOrganization(&OrganizationDTO{Slug: slug}) == nil; got != valid {
+			t.Errorf("slug %q valid = %v, want %v", slug, got, valid)
+		}
+	}
+}
+
+func TestTenantRolesLackPlatformPermissions(t *testing.T) {
+	manifest, err := LoadManifest("", KnownPermissions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	superadmin := ManifestRole{Name: "superadmin", Permissions: []string{AllPermissions}}
+
+	if got := manifest.RolePermissions(superadmin, DefaultOrganizationID); !slices.Contains(got, AuthIntrospectPermission) {
+		t.Errorf("superadmin of the default organization lacks %s", AuthIntrospectPermission)
+	}
+	tenant := manifest.RolePermissions(superadmin, 2)
+	for _, p := range PlatformPermissions {
+		if slices.Contains(tenant, p) {
+			t.Errorf("superadmin of a tenant holds platform permission %s", p)
+		}
+	}
+	if !slices.Contains(tenant, UsersCreatePermission) {
+		t.Errorf("superadmin of a tenant lacks %s", UsersCreatePermission)
+	}
+
+	if validateRolePermissions(2, []string{UsersReadPermission, PermissionsCreatePermission}) == nil {
+		t.Error("a tenant role accepted a platform permission")
+	}
+	if validateRolePermissions(DefaultOrganizationID, []string{PermissionsCreatePermission}) != nil {
+		t.Error("a default organization role refused a platform permission")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OutboxRelay publishes the events handlers stored in the outbox. Events are
+// claimed with SKIP LOCKED so several instances can relay side by side, a
+// failed event is retried with exponential backoff.
+type OutboxRelay struct {
+	DB         *pgxpool.Pool
+	Repo       *repository.Queries
+	Publisher  EventPublisher
+	Logger     *slog.Logger
+	Interval   time.Duration
+	BatchSize  int
+	MaxBackoff time.Duration
+	Retention  time.Duration
+}
+
+// Run relays on every interval until ctx is done, published events are
+// purged once a day
+func (r *OutboxRelay) Run(ctx context.Context) {
+	if r.Interval <= 0 || r.BatchSize <= 0 {
+		r.Logger.Info("event outbox relay disabled")
+		return
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	purged := time.Time{}
+
+	for {
+		// a fully published batch means more are waiting, relay again right away
+		for r.Relay(ctx) == r.BatchSize && ctx.Err() == nil {
+		}
+
+		if r.Retention > 0 && time.Since(purged) > 24*time.Hour {
+			r.Purge(ctx)
+			purged = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes one batch of pending events and returns how many it
+// published. Events go out in the order they were recorded, except that a
+// failed event is overtaken by later ones while it waits for its retry.
+func (r *OutboxRelay) Relay(ctx context.Context) int {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		r.Logger.Error("failed to relay events: ", "error", err)
+		return 0
+	}
+	defer tx.Rollback(ctx)
+	qtx := r.Repo.WithTx(tx)
+
+	pending, err := qtx.ClaimOutboxEvents(ctx, int32(r.BatchSize))
+	if err != nil {
+		r.Logger.Error("failed to claim events: ", "error", err)
+		return 0
+	}
+
+	published := 0
+	for _, row := range pending {
+		var event Event
+		err = json.Unmarshal(row.Payload, &event)
+		if err == nil {
+			err = r.Publisher.Publish(ctx, event)
+		}
+
+		if err != nil {
+			r.Logger.Warn("failed to publish event: ", "id", row.ID, "type", row.Type, "attempts", row.Attempts+1, "error", err)
+			err = qtx.MarkOutboxEventFailed(ctx, repository.MarkOutboxEventFailedParams{
+				ID:            row.ID,
+				LastError:     pgtype.Text{String: err.Error(), Valid: true},
+				NextAttemptAt: pgtype.Timestamp{Time: time.Now().Add(retryBackoff(r.Interval, r.MaxBackoff, row.Attempts)), Valid: true},
+			})
+		} else {
+			published++
+			err = qtx.MarkOutboxEventPublished(ctx, row.ID)
+		}
+		if err != nil {
+			r.Logger.Error("failed to update outbox: ", "id", row.ID, "error", err)
+			return 0
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		r.Logger.Error("failed to relay events: ", "error", err)
+		return 0
+	}
+	return published
+}
+
+// retryBackoff doubles the wait after every failed attempt, from base up to
+// max
+func retryBackoff(base, max time.Duration, attempts int32) time.Duration {
+	wait := base
+	for i := int32(0); i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	return min(wait, max)
+}
+
+func (r *OutboxRelay) Purge(ctx context.Context) {
+	retention := pgtype.Interval{Microseconds: r.Retention.Microseconds(), Valid: true}
+	events, err := r.Repo.PurgePublishedOutboxEvents(ctx, retention)
+	if err != nil {
+		r.Logger.Error("failed to purge published events: ", "error", err)
+		return
+	}
+	r.Logger.Info("purged published events", "events", events)
+}
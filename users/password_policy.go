@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrPasswordReused = errors.New("password has been used recently, choose a different one")
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// Fields reports the violations as field errors of the password
+func (e *PasswordPolicyError) Fields() ValidationErrors {
+	fields := make(ValidationErrors, 0)
+	for _, violation := range e.Violations {
+		fields = append(fields, FieldError{Field: "password", Rule: "password_policy", Message: violation})
+	}
+	return fields
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is in bytes, bcrypt ignores everything after the 72nd
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistorySize   int
+	MaxAge        time.Duration
+	Breached      *BreachedPasswords
+}
+
+func NewPasswordPolicy(cfg *Config) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		MaxLength:     cfg.PasswordMaxLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		HistorySize:   cfg.PasswordHistorySize,
+		MaxAge:        cfg.PasswordMaxAge,
+	}
+
+	if cfg.BreachedPasswordsFile != "" {
+		breached, err := LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return policy, err
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// Validate checks a password against the policy rules, username and email are
+// used to reject passwords that contain them
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	violations := make([]string, 0)
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must be at most %d bytes long", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		violations = append(violations, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		violations = append(violations, "password must not contain the username")
+	}
+	if local, _, _ := strings.Cut(email, "@"); len(local) >= 3 && strings.Contains(lowered, strings.ToLower(local)) {
+		violations = append(violations, "password must not contain the email")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, "password has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Expired reports whether a password set at changedAt has to be rotated
+func (p *PasswordPolicy) Expired(changedAt pgtype.Timestamp) bool {
+	if p.MaxAge <= 0 || !changedAt.Valid {
+		return false
+	}
+	return time.Since(changedAt.Time) > p.MaxAge
+}
+
+// BreachedPasswords is an in-memory copy of a breached password list keyed
+// like the k-anonymity range api: the first 5 hex chars of the SHA-1 hash
+// select a bucket holding the remaining suffixes
+type BreachedPasswords struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a file of upper or lower case SHA-1 hashes, one
+// per line, optionally followed by ":<count>" as in the HIBP downloads
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{ranges: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+		breached.add(strings.ToUpper(hash))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return breached, nil
+}
+
+func (b *BreachedPasswords) add(hash string) {
+	prefix, suffix := hash[:5], hash[5:]
+	if b.ranges[prefix] == nil {
+		b.ranges[prefix] = make(map[string]struct{})
+	}
+	b.ranges[prefix][suffix] = struct{}{}
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, ok := b.ranges[hash[:5]]
+	if !ok {
+		return false
+	}
+	_, ok = suffixes[hash[5:]]
+	return ok
+}
+
+// checkPasswordReuse rejects a password matching one of the user's last N hashes
+func (h *AuthHandler) checkPasswordReuse(userID int32, password string) error {
+	if h.Passwords.HistorySize <= 0 {
+		return nil
+	}
+
+	history, err := h.Repo.ListPasswordHistory(h.Ctx, repository.ListPasswordHistoryParams{
+		UserID: userID,
+		Limit:  int32(h.Passwords.HistorySize),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range history {
+		if ok, _ := h.Hasher.Verify(password, entry.PasswordHash); ok {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// recordPassword adds a new hash to the user's history and drops the ones
+// that fell out of the window, q lets callers do it inside a transaction
+func (h *AuthHandler) recordPassword(q *repository.Queries, userID int32, hashedPassword string) error {
+	if h.Passwords.HistorySize <= 0 {
+		return nil
+	}
+
+	err := q.CreatePasswordHistory(h.Ctx, repository.CreatePasswordHistoryParams{
+		UserID:       userID,
+		PasswordHash: hashedPassword,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.PrunePasswordHistory(h.Ctx, repository.PrunePasswordHistoryParams{
+		UserID: userID,
+		Limit:  int32(h.Passwords.HistorySize),
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 12, MaxLength: 72, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"valid", "Correct-Horse-9", nil},
+		{"too short", "Short-9a", []string{"at least 12 characters"}},
+		{"too long", "Correct-Horse-9" + strings.Repeat("é", 29), []string{"at most 72 bytes"}},
+		{"no upper", "correct-horse-9", []string{"uppercase"}},
+		{"no lower", "CORRECT-HORSE-9", []string{"lowercase"}},
+		{"no digit", "Correct-Horse-X", []string{"digit"}},
+		{"no symbol", "CorrectHorse99", []string{"symbol"}},
+		{"contains the username", "Jdoe-Secret-99", []string{"username"}},
+		{"contains the email", "Janed0e-Secret!", []string{"email"}},
+		{"every rule", "abc", []string{"at least", "uppercase", "digit", "symbol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "jdoe", "janed0e@example.com")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("err = %v, want a policy error", err)
+			}
+			if len(policyErr.Violations) != len(tt.want) {
+				t.Fatalf("violations = %q, want %d", policyErr.Violations, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(policyErr.Violations[i], want) {
+					t.Errorf("violation %q does not mention %q", policyErr.Violations[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	sha := func(password string) string {
+		sum := sha1.Sum([]byte(password))
+		return hex.EncodeToString(sum[:])
+	}
+
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	lines := []string{
+		strings.ToUpper(sha("password123")) + ":24230577",
+		sha("letmein"),
+		"not a hash",
+		"",
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	breached, err := LoadBreachedPasswords(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for password, want := range map[string]bool{"password123": true, "letmein": true, "Password123": false, "correct horse": false} {
+		if got := breached.Contains(password); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", password, got, want)
+		}
+	}
+
+	policy := &PasswordPolicy{Breached: breached}
+	if err = policy.Validate("letmein", "", ""); err == nil || !strings.Contains(err.Error(), "breach") {
+		t.Errorf("err = %v, want a breached password violation", err)
+	}
+
+	if _, err = NewPasswordPolicy(&Config{BreachedPasswordsFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("a missing breached password file must fail the policy")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import "slices"
+
+// Every permission a route or handler can require. Routes reference these
+// constants, the bootstrap seeds them and the router refuses to start when a
+// route asks for a permission that is not listed in KnownPermissions.
+const (
+	PermissionsListPermission    = "permissions:list"
+	PermissionsCreatePermission  = "permissions:create"
+	PermissionsDeletePermission  = "permissions:delete"
+	PermissionsRestorePermission = "permissions:restore"
+
+	RolesListPermission    = "roles:list"
+	RolesReadPermission    = "roles:read"
+	RolesCreatePermission  = "roles:create"
+	RolesUpdatePermission  = "roles:update"
+	RolesDeletePermission  = "roles:delete"
+	RolesRestorePermission = "roles:restore"
+
+	UsersListPermission    = "users:list"
+	UsersReadPermission    = "users:read"
+	UsersCreatePermission  = "users:create"
+	UsersUpdatePermission  = "users:update"
+	UsersDeletePermission  = "users:delete"
+	UsersRestorePermission = "users:restore"
+	UsersExportPermission  = "users:export"
+	UsersErasePermission   = "users:erase"
+	UsersImportPermission  = "users:import"
+	UsersApprovePermission = "users:approve"
+
+	InvitationsListPermission   = "invitations:list"
+	InvitationsCreatePermission = "invitations:create"
+	InvitationsDeletePermission = "invitations:delete"
+
+	SessionsListPermission   = "sessions:list"
+	SessionsDeletePermission = "sessions:delete"
+
+	ApiKeysManagePermission = "api_keys:manage"
+
+	WebhooksListPermission   = "webhooks:list"
+	WebhooksCreatePermission = "webhooks:create"
+	WebhooksUpdatePermission = "webhooks:update"
+	WebhooksDeletePermission = "webhooks:delete"
+
+	OrganizationsListPermission    = "organizations:list"
+	OrganizationsCreatePermission  = "organizations:create"
+	OrganizationsUpdatePermission  = "organizations:update"
+	OrganizationsMembersPermission = "organizations:members"
+
+	GroupsListPermission    = "groups:list"
+	GroupsCreatePermission  = "groups:create"
+	GroupsUpdatePermission  = "groups:update"
+	GroupsDeletePermission  = "groups:delete"
+	GroupsMembersPermission = "groups:members"
+	GroupsRolesPermission   = "groups:roles"
+
+	// granted to services that validate credentials over grpc
+	AuthIntrospectPermission = "auth:introspect"
+)
+
+var KnownPermissions = []string{
+	PermissionsListPermission,
+	PermissionsCreatePermission,
+	PermissionsDeletePermission,
+	PermissionsRestorePermission,
+	RolesListPermission,
+	RolesReadPermission,
+	RolesCreatePermission,
+	RolesUpdatePermission,
+	RolesDeletePermission,
+	RolesRestorePermission,
+	UsersListPermission,
+	UsersReadPermission,
+	UsersCreatePermission,
+	UsersUpdatePermission,
+	UsersDeletePermission,
+	UsersRestorePermission,
+	UsersExportPermission,
+	UsersErasePermission,
+	UsersImportPermission,
+	UsersApprovePermission,
+	InvitationsListPermission,
+	InvitationsCreatePermission,
+	InvitationsDeletePermission,
+	SessionsListPermission,
+	SessionsDeletePermission,
+	ApiKeysManagePermission,
+	WebhooksListPermission,
+	WebhooksCreatePermission,
+	WebhooksUpdatePermission,
+	WebhooksDeletePermission,
+	OrganizationsListPermission,
+	OrganizationsCreatePermission,
+	OrganizationsUpdatePermission,
+	OrganizationsMembersPermission,
+	GroupsListPermission,
+	GroupsCreatePermission,
+	GroupsUpdatePermission,
+	GroupsDeletePermission,
+	GroupsMembersPermission,
+	GroupsRolesPermission,
+	AuthIntrospectPermission,
+}
+
+// PlatformPermissions act on every organization, only roles of the default
+// organization can hold them
+var PlatformPermissions = []string{
+	PermissionsCreatePermission,
+	PermissionsDeletePermission,
+	PermissionsRestorePermission,
+	OrganizationsCreatePermission,
+	OrganizationsUpdatePermission,
+	AuthIntrospectPermission,
+}
+
+// tenantPermissions drops the platform permissions outside the default
+// organization
+func tenantPermissions(organizationID int32, permissions []string) []string {
+	if organizationID == DefaultOrganizationID {
+		return permissions
+	}
+	granted := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if !slices.Contains(PlatformPermissions, p) {
+			granted = append(granted, p)
+		}
+	}
+	return granted
+}
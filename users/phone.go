@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// otp purposes
+const (
+	OtpVerifyPhone = "verify_phone"
+	OtpLogin       = "login"
+)
+
+var (
+	ErrInvalidPhone = errors.New("phone number must be in international format, e.g. +14155552671")
+	ErrInvalidOtp   = errors.New("invalid or expired code")
+
+	ErrOtpRateLimited = &DomainError{Status: http.StatusTooManyRequests, Code: CodeTooManyRequests, Message: "too many codes requested, try again later"}
+
+	e164Pattern     = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+)
+
+// NormalizePhone turns a phone number into E.164. Separators are dropped, a
+// leading 00 becomes +, and national numbers get defaultCountryCode (e.g. "44")
+// with their trunk 0 removed when one is configured.
+func NormalizePhone(phone, defaultCountryCode string) (string, error) {
+	phone = phoneSeparators.Replace(strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	case defaultCountryCode != "":
+		phone = "+" + strings.TrimPrefix(defaultCountryCode, "+") + strings.TrimPrefix(phone, "0")
+	}
+
+	if !e164Pattern.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// normalizePhoneNumber rewrites a bound phone number to E.164 in place, empty
+// numbers are stored as NULL and invalid ones reported like failed validation
+func (h *AuthHandler) normalizePhoneNumber(phone *pgtype.Text) error {
+	if !phone.Valid || phone.String == "" {
+		*phone = pgtype.Text{}
+		return nil
+	}
+
+	normalized, err := NormalizePhone(phone.String, h.Cfg.PhoneDefaultCountryCode)
+	if err != nil {
+		return ValidationErrors{{Field: "phone_number", Rule: "e164", Message: err.Error()}}
+	}
+	*phone = pgtype.Text{String: normalized, Valid: true}
+	return nil
+}
+
+func generateOtp() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// sendOtp texts a new code for purpose to phone, replacing any earlier code.
+// Codes are refused within the resend cooldown and past the cap per window,
+// every text costs money and each one is another guess at a login.
+func (h *AuthHandler) sendOtp(userID int32, purpose, phone string) error {
+	recent, err := h.Repo.CountRecentPhoneOtps(h.Ctx, repository.CountRecentPhoneOtpsParams{
+		Period:   pgtype.Interval{Microseconds: h.Cfg.OtpWindow.Microseconds(), Valid: true},
+		Cooldown: pgtype.Interval{Microseconds: h.Cfg.OtpResendCooldown.Microseconds(), Valid: true},
+		UserID:   userID,
+	})
+	if err != nil {
+		return err
+	}
+	if recent.Cooling > 0 || (h.Cfg.OtpMaxPerWindow > 0 && int(recent.Sent) >= h.Cfg.OtpMaxPerWindow) {
+		return ErrOtpRateLimited
+	}
+
+	code, err := generateOtp()
+	if err != nil {
+		return err
+	}
+
+	err = h.Repo.InvalidatePhoneOtps(h.Ctx, repository.InvalidatePhoneOtpsParams{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = h.Repo.CreatePhoneOtp(h.Ctx, repository.CreatePhoneOtpParams{
+		UserID:      userID,
+		Purpose:     purpose,
+		PhoneNumber: phone,
+		CodeHash:    HashApiKey(code),
+		ExpiresAt:   pgtype.Timestamp{Time: time.Now().Add(h.Cfg.OtpTTL), Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your verification code is %s, it expires in %s.", code, h.Cfg.OtpTTL)
+	return h.Sms.Send(h.Ctx, phone, message)
+}
+
+// checkOtp consumes the user's current code for purpose if it matches. Wrong
+// guesses count against the code, which stops working after too many.
+func (h *AuthHandler) checkOtp(userID int32, purpose, code string) (repository.PhoneOtp, error) {
+	otp, err := h.Repo.GetActivePhoneOtp(h.Ctx, repository.GetActivePhoneOtpParams{
+		UserID:      userID,
+		Purpose:     purpose,
+		MaxAttempts: int32(h.Cfg.OtpMaxAttempts),
+	})
+	if err != nil {
+		return otp, ErrInvalidOtp
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.CodeHash), []byte(HashApiKey(code))) != 1 {
+		if err := h.Repo.IncrementPhoneOtpAttempts(h.Ctx, otp.ID); err != nil {
+			return otp, err
+		}
+		return otp, ErrInvalidOtp
+	}
+
+	consumed, err := h.Repo.ConsumePhoneOtp(h.Ctx, otp.ID)
+	if err != nil {
+		return otp, err
+	}
+	if consumed == 0 {
+		return otp, ErrInvalidOtp
+	}
+	return otp, nil
+}
+
+// startSmsChallenge texts a login code instead of issuing tokens, the client
+// exchanges the challenge and the code at /login/verify
+func (h *AuthHandler) startSmsChallenge(c echo.Context, user repository.User) error {
+	if err := h.sendOtp(user.ID, OtpLogin, user.PhoneNumber.String); err != nil {
+		if errors.Is(err, ErrOtpRateLimited) {
+			return err
+		}
+		return InternalError(err)
+	}
+
+	challenge, err := GenerateToken(h.Cfg, TwoFactorToken, int64(user.ID), user.Role, tenantID(c), 0, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	responseData := TwoFactorChallengeDTO{
+		TwoFactorRequired: true,
+		Challenge:         challenge,
+	}
+	return NewResponse(c, "success", responseData, "", http.StatusAccepted)
+}
+
+// phone handlers
+
+func (h *AuthHandler) RequestPhoneVerification(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "only users can verify a phone number", http.StatusBadRequest)
+	}
+
+	if !user.PhoneNumber.Valid {
+		return NewResponse(c, "failed", nil, "no phone number set", http.StatusBadRequest)
+	}
+
+	if user.PhoneVerified.Bool {
+		return NewResponse(c, "failed", nil, "phone number is already verified", http.StatusConflict)
+	}
+
+	if err = h.sendOtp(user.ID, OtpVerifyPhone, user.PhoneNumber.String); err != nil {
+		if errors.Is(err, ErrOtpRateLimited) {
+			return err
+		}
+		return InternalError(err)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusAccepted)
+}
+
+func (h *AuthHandler) ConfirmPhoneVerification(c echo.Context) error {
+	data := new(VerifyPhoneDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "only users can verify a phone number", http.StatusBadRequest)
+	}
+
+	otp, err := h.checkOtp(user.ID, OtpVerifyPhone, data.Code)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	// the number may have changed since the code was sent
+	verified, err := h.Repo.VerifyUserPhone(h.Ctx, repository.VerifyUserPhoneParams{
+		ID:             user.ID,
+		PhoneNumber:    pgtype.Text{String: otp.PhoneNumber, Valid: true},
+		OrganizationID: user.OrganizationID,
+	})
+	if err != nil {
+		return DBError(err, "user")
+	}
+	if verified == 0 {
+		return NewResponse(c, "failed", nil, ErrInvalidOtp.Error(), http.StatusBadRequest)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+// SetSmsTwoFactor turns the login code on or off, it asks for the password
+// like other security settings
+func (h *AuthHandler) SetSmsTwoFactor(c echo.Context) error {
+	data := new(SmsTwoFactorDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	user, err := tenantUser(h.Ctx, h.Repo, userID, tenantID(c))
+	if err != nil {
+		return NewResponse(c, "failed", nil, "only users can use two factor authentication", http.StatusBadRequest)
+	}
+
+	if ok, _ := h.Hasher.Verify(data.Password, user.Password); !ok {
+		return NewResponse(c, "failed", nil, "invalid password", http.StatusUnauthorized)
+	}
+
+	if data.Enabled && !user.PhoneVerified.Bool {
+		return NewResponse(c, "failed", nil, "verify your phone number first", http.StatusBadRequest)
+	}
+
+	err = h.Repo.SetUserSmsTwoFactor(h.Ctx, repository.SetUserSmsTwoFactorParams{
+		ID:             user.ID,
+		SmsTwoFactor:   pgtype.Bool{Bool: data.Enabled, Valid: true},
+		OrganizationID: user.OrganizationID,
+	})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+// VerifyLogin completes a login that was answered with an sms challenge
+func (h *AuthHandler) VerifyLogin(c echo.Context) error {
+	data := new(VerifyLoginDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	claims, err := ParseToken(h.Cfg, data.Challenge, TwoFactorToken)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnauthorized)
+	}
+
+	if err = useCredentialTenant(c, h.Repo, claims.OrganizationID); err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnauthorized)
+	}
+
+	user, err := tenantUser(h.Ctx, h.Repo, claims.UserID, claims.OrganizationID)
+	if err != nil {
+		return NewResponse(c, "failed", nil, "user no longer exists", http.StatusUnauthorized)
+	}
+
+	if err = canSignIn(user); err != nil {
+		return err
+	}
+
+	if _, err = h.checkOtp(user.ID, OtpLogin, data.Code); err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnauthorized)
+	}
+
+	return h.startSession(c, user, data.Device)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	EventPublisherLog       = "log"
+	EventPublisherInProcess = "inprocess"
+	EventPublisherWebhook   = "webhook"
+	EventPublisherNats      = "nats"
+	EventPublisherKafka     = "kafka"
+)
+
+// EventPublisher hands events from the outbox to consumers. Deliveries are
+// at least once, an event whose publishing failed is published again.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// NewEventPublisher gives the http publishers a timeout, the outbox relay
+// holds its rows locked while publishing
+func NewEventPublisher(cfg *Config, logger *slog.Logger) (EventPublisher, error) {
+	client := &http.Client{Timeout: cfg.EventTimeout}
+	switch cfg.EventPublisher {
+	case EventPublisherLog:
+		return &LogEventPublisher{Logger: logger}, nil
+	case EventPublisherInProcess:
+		return NewEventBus(), nil
+	case EventPublisherWebhook:
+		if cfg.EventWebhookURL == "" {
+			return nil, errors.New("the webhook event publisher needs EVENT_WEBHOOK_URL")
+		}
+		return &WebhookEventPublisher{URL: cfg.EventWebhookURL, Client: client}, nil
+	case EventPublisherNats:
+		conn, err := nats.Connect(cfg.NatsURL, nats.Name("users"))
+		if err != nil {
+			return nil, fmt.Errorf("connect to nats: %w", err)
+		}
+		return &NatsEventPublisher{Conn: conn, SubjectPrefix: cfg.NatsSubjectPrefix, Timeout: cfg.EventTimeout}, nil
+	case EventPublisherKafka:
+		if cfg.KafkaRestURL == "" {
+			return nil, errors.New("the kafka event publisher needs KAFKA_REST_URL")
+		}
+		return &KafkaEventPublisher{URL: cfg.KafkaRestURL, Topic: cfg.KafkaTopic, Client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher %s", cfg.EventPublisher)
+	}
+}
+
+// LogEventPublisher writes events to the log instead of publishing them
+type LogEventPublisher struct {
+	Logger *slog.Logger
+}
+
+func (p *LogEventPublisher) Publish(ctx context.Context, event Event) error {
+	p.Logger.Info("event", "id", event.ID, "type", event.Type, "subject", event.Subject, "data", string(event.Data))
+	return nil
+}
+
+// EventHandler consumes an event published in process
+type EventHandler func(ctx context.Context, event Event) error
+
+// EventBus delivers events to handlers in this process, handlers
+// subscribed to "*" get every event
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[string][]EventHandler)}
+}
+
+func (b *EventBus) Subscribe(eventType string, handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+}
+
+// Publish runs every matching handler, the event counts as failed when any
+// of them fails and all of them see it again on the next attempt
+func (b *EventBus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := append(append([]EventHandler{}, b.handlers[event.Type]...), b.handlers["*"]...)
+	b.mu.RUnlock()
+
+	errs := make([]error, 0)
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookEventPublisher posts every event as json to one url, any answer
+// but a 2xx is a failure
+type WebhookEventPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *WebhookEventPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	return sendPublishRequest(p.Client, req)
+}
+
+// NatsEventPublisher publishes to <prefix>.<type>, e.g.
+// users.events.user.registered. The event id goes into the Nats-Msg-Id
+// header so JetStream streams drop redeliveries.
+type NatsEventPublisher struct {
+	Conn          *nats.Conn
+	SubjectPrefix string
+	Timeout       time.Duration
+}
+
+func (p *NatsEventPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(p.SubjectPrefix + "." + event.Type)
+	msg.Data = body
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	if err = p.Conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	// publishing is buffered, only a flush tells whether the server got it,
+	// and nats wants a deadline for it
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	return p.Conn.FlushWithContext(ctx)
+}
+
+// KafkaEventPublisher produces to a topic through a Kafka REST proxy, the v2
+// api both the Confluent REST proxy and Redpanda's http proxy serve. Records
+// are keyed by subject so the events of one record stay in order.
+type KafkaEventPublisher struct {
+	URL    string
+	Topic  string
+	Client *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (p *KafkaEventPublisher) Publish(ctx context.Context, event Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": {{
+		Key:   base64.StdEncoding.EncodeToString([]byte(event.Subject)),
+		Value: base64.StdEncoding.EncodeToString(value),
+	}}})
+	if err != nil {
+		return err
+	}
+
+	u := p.URL + "/topics/" + url.PathEscape(p.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err = publishStatusError(req, res); err != nil {
+		return err
+	}
+
+	// the proxy answers 200 and reports failed records per offset
+	var produced struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("decode kafka proxy answer: %w", err)
+	}
+	for _, offset := range produced.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka rejected the record: %d %s", *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
+
+func sendPublishRequest(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return publishStatusError(req, res)
+}
+
+// publishStatusError fails any answer but a 2xx
+func publishStatusError(req *http.Request, res *http.Response) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), res.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
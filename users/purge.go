@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PurgeJob hard deletes soft deleted users, roles and permissions once they
+// have been deleted for longer than the retention period
+type PurgeJob struct {
+	Repo      *repository.Queries
+	Logger    *slog.Logger
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// Run purges once right away and then on every interval until ctx is done
+func (j *PurgeJob) Run(ctx context.Context) {
+	if j.Retention <= 0 || j.Interval <= 0 {
+		j.Logger.Info("soft delete purge job disabled")
+		return
+	}
+
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *PurgeJob) Purge(ctx context.Context) {
+	retention := pgtype.Interval{Microseconds: j.Retention.Microseconds(), Valid: true}
+
+	// users go first so roles they were holding on to can be purged in the same run
+	users, err := j.Repo.PurgeDeletedUsers(ctx, retention)
+	if err != nil {
+		j.Logger.Error("failed to purge deleted users: ", "error", err)
+	}
+
+	roles, err := j.Repo.PurgeDeletedRoles(ctx, retention)
+	if err != nil {
+		j.Logger.Error("failed to purge deleted roles: ", "error", err)
+	}
+
+	permissions, err := j.Repo.PurgeDeletedPermissions(ctx, retention)
+	if err != nil {
+		j.Logger.Error("failed to purge deleted permissions: ", "error", err)
+	}
+
+	j.Logger.Info("purged soft deleted records",
+		"users", users,
+		"roles", roles,
+		"permissions", permissions,
+	)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"users/repository"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+var ErrRefreshTokenReused = errors.New("refresh token has already been used, sign in again")
+
+// RefreshToken exchanges the refresh token of a login for a new access token
+// and a new refresh token. Both are bound to the login's session, which only
+// accepts the latest refresh token: a used one coming back means it leaked,
+// so the session is revoked. Every refresh picks up role, membership and
+// permission changes.
+func (h *AuthHandler) RefreshToken(c echo.Context) error {
+	data := new(RefreshTokenDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+
+	claims, err := ParseToken(h.Cfg, data.RefreshToken, RefreshToken)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnauthorized)
+	}
+
+	if err = useCredentialTenant(c, h.Repo, claims.OrganizationID); err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnauthorized)
+	}
+
+	// reload the user so role, membership and permission changes are picked up
+	user, err := tenantUser(h.Ctx, h.Repo, claims.UserID, claims.OrganizationID)
+	if err != nil {
+		return NewResponse(c, "failed", nil, "user no longer exists", http.StatusUnauthorized)
+	}
+
+	if err = canSignIn(user); err != nil {
+		return err
+	}
+
+	permissions, err := userPermissions(h.Ctx, h.Repo, user, claims.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	// the session takes the new refresh token only in exchange for its latest one
+	refreshID := uuid.New().String()
+	rotated, err := h.Repo.RotateSessionRefreshToken(h.Ctx, repository.RotateSessionRefreshTokenParams{
+		ID:                claims.SessionID,
+		RefreshTokenID:    pgtype.Text{String: claims.ID, Valid: true},
+		NewRefreshTokenID: pgtype.Text{String: refreshID, Valid: true},
+	})
+	if err != nil {
+		return DBError(err, "session")
+	}
+	if rotated == 0 {
+		if session, err := h.Repo.GetActiveSession(h.Ctx, claims.SessionID); err == nil {
+			if err = h.Repo.RevokeSession(h.Ctx, session.ID); err != nil {
+				return DBError(err, "session")
+			}
+			return NewResponse(c, "failed", nil, ErrRefreshTokenReused.Error(), http.StatusUnauthorized)
+		}
+		return NewResponse(c, "failed", nil, ErrSessionRevoked.Error(), http.StatusUnauthorized)
+	}
+
+	token, err := GenerateToken(h.Cfg, AccessToken, int64(user.ID), user.Role, claims.OrganizationID, claims.SessionID, permissions)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	refreshToken, err := signToken(h.Cfg, RefreshToken, int64(user.ID), user.Role, claims.OrganizationID, claims.SessionID, nil, refreshID)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	responseData := AccessTokenDTO{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
+	return NewResponse(c, "success", responseData, "", http.StatusOK)
+}
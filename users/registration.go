@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"users/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registration modes
+const (
+	RegistrationOpen            = "open"
+	RegistrationInviteOnly      = "invite_only"
+	RegistrationDomainAllowlist = "domain_allowlist"
+)
+
+// checkRegistration reports whether email may sign up on its own to an
+// organization. Organizations other than the default one have to opt in,
+// unknown modes close registration rather than open it.
+func (h *AuthHandler) checkRegistration(organizationID int32, email string) error {
+	org, err := h.Repo.GetOrganization(h.Ctx, organizationID)
+	if err != nil {
+		return DBError(err, "organization")
+	}
+	if !org.AllowRegistration {
+		return &DomainError{Status: http.StatusForbidden, Code: CodeRegistrationClosed, Message: "registration is by invitation only"}
+	}
+
+	switch h.Cfg.RegistrationMode {
+	case RegistrationOpen:
+		return nil
+	case RegistrationDomainAllowlist:
+		_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+		for _, allowed := range h.Cfg.RegistrationAllowedDomains {
+			if domain == strings.ToLower(allowed) {
+				return nil
+			}
+		}
+		return &DomainError{Status: http.StatusForbidden, Code: CodeEmailDomainDenied, Message: "registration is not open to this email domain"}
+	}
+	return &DomainError{Status: http.StatusForbidden, Code: CodeRegistrationClosed, Message: "registration is by invitation only"}
+}
+
+// registration handlers
+
+func (h *AuthHandler) ListPendingApprovals(c echo.Context) error {
+	users, err := h.Repo.ListPendingApprovalUsers(h.Ctx, tenantID(c))
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	usersDTO := make([]UserGetDTO, 0)
+	for _, user := range users {
+		usersDTO = append(usersDTO, UserGetDTO{
+			ID:              user.ID,
+			OrganizationID:  user.OrganizationID,
+			Username:        user.Username,
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+			PhoneNumber:     user.PhoneNumber,
+			PhoneVerified:   user.PhoneVerified,
+			SmsTwoFactor:    user.SmsTwoFactor,
+			IsActive:        user.IsActive,
+			IsVerified:      user.IsVerified,
+			ApprovalPending: user.ApprovalPending,
+			Role:            user.Role,
+			CreatedAt:       user.CreatedAt,
+			UpdatedAt:       user.UpdatedAt,
+			DeletedAt:       user.DeletedAt,
+		})
+	}
+
+	return NewResponse(c, "success", usersDTO, "", http.StatusOK)
+}
+
+// ApproveUser activates a self registered user and lets them know by email
+func (h *AuthHandler) ApproveUser(c echo.Context) error {
+	return h.decideApproval(c, true)
+}
+
+// RejectUser deletes a self registered user, the address can register again
+// and the record is purged with other deleted users
+func (h *AuthHandler) RejectUser(c echo.Context) error {
+	return h.decideApproval(c, false)
+}
+
+func (h *AuthHandler) decideApproval(c echo.Context, approve bool) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, err := h.Repo.GetUser(h.Ctx, repository.GetUserParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "user")
+	}
+
+	tx, err := h.DB.Begin(h.Ctx)
+	if err != nil {
+		return InternalError(err)
+	}
+	defer tx.Rollback(h.Ctx)
+
+	qtx := h.Repo.WithTx(tx)
+	action, event, subject, body := AuditUserApprove, EventUserActivated, "Account Approved", "Your account was approved, you can now log in."
+	var decided int64
+	if approve {
+		decided, err = qtx.ApproveUser(h.Ctx, repository.ApproveUserParams{ID: user.ID, OrganizationID: user.OrganizationID})
+	} else {
+		action, event, subject, body = AuditUserReject, EventUserDeleted, "Registration Declined", "Your registration was declined by an administrator."
+		decided, err = qtx.RejectUser(h.Ctx, repository.RejectUserParams{ID: user.ID, OrganizationID: user.OrganizationID})
+	}
+	if err != nil {
+		return DBError(err, "user")
+	}
+	if decided == 0 {
+		return NotFoundError("user waiting for approval not found")
+	}
+
+	if err = recordAudit(h.Ctx, qtx, c, action, user.ID, nil); err != nil {
+		return DBError(err, "audit entry")
+	}
+	if err = recordEvent(h.Ctx, qtx, c, event, userSubject(user.ID), UserEventData{UserID: user.ID}); err != nil {
+		return InternalError(err)
+	}
+
+	if err = tx.Commit(h.Ctx); err != nil {
+		return InternalError(err)
+	}
+
+	// the decision stands even if the notice cannot be delivered
+	if err = SendEmail(h.Cfg.EmailFrom, h.Cfg.EmailPassword, user.Email, subject, body); err != nil {
+		h.Logger.Error("failed to send approval notice: ", "error", err)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
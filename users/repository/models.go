@@ -8,6 +8,123 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiKey struct {
+	ID             int32            `json:"id"`
+	Name           string           `json:"name"`
+	Prefix         string           `json:"prefix"`
+	KeyHash        string           `json:"key_hash"`
+	UserID         pgtype.Int4      `json:"user_id"`
+	ServiceAccount pgtype.Text      `json:"service_account"`
+	Permissions    []string         `json:"permissions"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	LastUsedAt     pgtype.Timestamp `json:"last_used_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	RevokedAt      pgtype.Timestamp `json:"revoked_at"`
+	OrganizationID int32            `json:"organization_id"`
+}
+
+type AuditLog struct {
+	ID        int32            `json:"id"`
+	ActorID   pgtype.Int4      `json:"actor_id"`
+	Action    string           `json:"action"`
+	UserID    int32            `json:"user_id"`
+	Metadata  []byte           `json:"metadata"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type EmailChange struct {
+	ID               int32            `json:"id"`
+	UserID           int32            `json:"user_id"`
+	NewEmail         string           `json:"new_email"`
+	ConfirmTokenHash string           `json:"confirm_token_hash"`
+	CancelTokenHash  string           `json:"cancel_token_hash"`
+	ExpiresAt        pgtype.Timestamp `json:"expires_at"`
+	ConfirmedAt      pgtype.Timestamp `json:"confirmed_at"`
+	CancelledAt      pgtype.Timestamp `json:"cancelled_at"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+}
+
+type EventOutbox struct {
+	ID            int64            `json:"id"`
+	EventID       pgtype.UUID      `json:"event_id"`
+	Type          string           `json:"type"`
+	Version       int32            `json:"version"`
+	Payload       []byte           `json:"payload"`
+	Attempts      int32            `json:"attempts"`
+	LastError     pgtype.Text      `json:"last_error"`
+	NextAttemptAt pgtype.Timestamp `json:"next_attempt_at"`
+	PublishedAt   pgtype.Timestamp `json:"published_at"`
+	CreatedAt     pgtype.Timestamp `json:"created_at"`
+}
+
+type Group struct {
+	ID             int32            `json:"id"`
+	OrganizationID int32            `json:"organization_id"`
+	Name           string           `json:"name"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+}
+
+type GroupMember struct {
+	GroupID   int32            `json:"group_id"`
+	UserID    int32            `json:"user_id"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type GroupRole struct {
+	GroupID   int32            `json:"group_id"`
+	RoleID    int32            `json:"role_id"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type Invitation struct {
+	ID             int32            `json:"id"`
+	Email          string           `json:"email"`
+	Role           int64            `json:"role"`
+	TokenHash      string           `json:"token_hash"`
+	InvitedBy      pgtype.Int4      `json:"invited_by"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	AcceptedAt     pgtype.Timestamp `json:"accepted_at"`
+	RevokedAt      pgtype.Timestamp `json:"revoked_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	OrganizationID int32            `json:"organization_id"`
+}
+
+type Organization struct {
+	ID                int32            `json:"id"`
+	Name              string           `json:"name"`
+	Slug              string           `json:"slug"`
+	IsActive          bool             `json:"is_active"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+	AllowRegistration bool             `json:"allow_registration"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int32            `json:"organization_id"`
+	UserID         int32            `json:"user_id"`
+	Role           int64            `json:"role"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+}
+
+type PasswordHistory struct {
+	ID           int32            `json:"id"`
+	UserID       int32            `json:"user_id"`
+	PasswordHash string           `json:"password_hash"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+}
+
+type PasswordReset struct {
+	ID        int32            `json:"id"`
+	UserID    int32            `json:"user_id"`
+	TokenHash string           `json:"token_hash"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
 type Permission struct {
 	ID        int32            `json:"id"`
 	Name      string           `json:"name"`
@@ -16,27 +133,97 @@ type Permission struct {
 	DeletedAt pgtype.Timestamp `json:"deleted_at"`
 }
 
-type Role struct {
+type PhoneOtp struct {
 	ID          int32            `json:"id"`
-	RoleName    string           `json:"role_name"`
-	Permissions []string         `json:"permissions"`
+	UserID      int32            `json:"user_id"`
+	Purpose     string           `json:"purpose"`
+	PhoneNumber string           `json:"phone_number"`
+	CodeHash    string           `json:"code_hash"`
+	Attempts    int32            `json:"attempts"`
+	ExpiresAt   pgtype.Timestamp `json:"expires_at"`
+	ConsumedAt  pgtype.Timestamp `json:"consumed_at"`
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
-	DeletedAt   pgtype.Timestamp `json:"deleted_at"`
+}
+
+type Role struct {
+	ID             int32            `json:"id"`
+	RoleName       string           `json:"role_name"`
+	Permissions    []string         `json:"permissions"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	DeletedAt      pgtype.Timestamp `json:"deleted_at"`
+	OrganizationID int32            `json:"organization_id"`
+}
+
+type Session struct {
+	ID             int32            `json:"id"`
+	UserID         int32            `json:"user_id"`
+	Device         pgtype.Text      `json:"device"`
+	IpAddress      pgtype.Text      `json:"ip_address"`
+	UserAgent      pgtype.Text      `json:"user_agent"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	LastSeenAt     pgtype.Timestamp `json:"last_seen_at"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	RevokedAt      pgtype.Timestamp `json:"revoked_at"`
+	OrganizationID int32            `json:"organization_id"`
+	RefreshTokenID pgtype.Text      `json:"refresh_token_id"`
 }
 
 type User struct {
-	ID          int32            `json:"id"`
-	Username    string           `json:"username"`
-	Email       string           `json:"email"`
-	Password    string           `json:"password"`
-	FirstName   pgtype.Text      `json:"first_name"`
-	LastName    pgtype.Text      `json:"last_name"`
-	PhoneNumber pgtype.Text      `json:"phone_number"`
-	IsActive    pgtype.Bool      `json:"is_active"`
-	IsVerified  pgtype.Bool      `json:"is_verified"`
-	Role        int64            `json:"role"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
-	DeletedAt   pgtype.Timestamp `json:"deleted_at"`
+	ID                int32            `json:"id"`
+	Username          string           `json:"username"`
+	Email             string           `json:"email"`
+	Password          string           `json:"password"`
+	FirstName         pgtype.Text      `json:"first_name"`
+	LastName          pgtype.Text      `json:"last_name"`
+	PhoneNumber       pgtype.Text      `json:"phone_number"`
+	IsActive          pgtype.Bool      `json:"is_active"`
+	IsVerified        pgtype.Bool      `json:"is_verified"`
+	Role              int64            `json:"role"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+	DeletedAt         pgtype.Timestamp `json:"deleted_at"`
+	PasswordChangedAt pgtype.Timestamp `json:"password_changed_at"`
+	AnonymizedAt      pgtype.Timestamp `json:"anonymized_at"`
+	PhoneVerified     pgtype.Bool      `json:"phone_verified"`
+	SmsTwoFactor      pgtype.Bool      `json:"sms_two_factor"`
+	ApprovalPending   pgtype.Bool      `json:"approval_pending"`
+	OrganizationID    int32            `json:"organization_id"`
+}
+
+type WebhookDelivery struct {
+	ID             int64            `json:"id"`
+	SubscriptionID int32            `json:"subscription_id"`
+	EventID        pgtype.UUID      `json:"event_id"`
+	EventType      string           `json:"event_type"`
+	Payload        []byte           `json:"payload"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamp `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4      `json:"last_status_code"`
+	LastError      pgtype.Text      `json:"last_error"`
+	DeliveredAt    pgtype.Timestamp `json:"delivered_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          int64            `json:"id"`
+	DeliveryID  int64            `json:"delivery_id"`
+	StatusCode  pgtype.Int4      `json:"status_code"`
+	Error       pgtype.Text      `json:"error"`
+	DurationMs  int32            `json:"duration_ms"`
+	AttemptedAt pgtype.Timestamp `json:"attempted_at"`
+}
+
+type WebhookSubscription struct {
+	ID             int32            `json:"id"`
+	Url            string           `json:"url"`
+	Events         []string         `json:"events"`
+	Secret         string           `json:"secret"`
+	Description    pgtype.Text      `json:"description"`
+	IsActive       bool             `json:"is_active"`
+	CreatedBy      pgtype.Int4      `json:"created_by"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	OrganizationID int32            `json:"organization_id"`
 }
@@ -15,11 +15,16 @@ const activateUser = `-- name: ActivateUser :exec
 UPDATE users
 SET is_active = TRUE,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
 `
 
-func (q *Queries) ActivateUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, activateUser, id)
+type ActivateUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) ActivateUser(ctx context.Context, arg ActivateUserParams) error {
+	_, err := q.db.Exec(ctx, activateUser, arg.ID, arg.OrganizationID)
 	return err
 }
 
@@ -27,19 +32,71 @@ const addPermissionToRole = `-- name: AddPermissionToRole :exec
 UPDATE roles
 SET permissions = array_append(permissions, $2),
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
 `
 
 type AddPermissionToRoleParams struct {
-	ID          int32       `json:"id"`
-	ArrayAppend interface{} `json:"array_append"`
+	ID             int32       `json:"id"`
+	ArrayAppend    interface{} `json:"array_append"`
+	OrganizationID int32       `json:"organization_id"`
 }
 
 func (q *Queries) AddPermissionToRole(ctx context.Context, arg AddPermissionToRoleParams) error {
-	_, err := q.db.Exec(ctx, addPermissionToRole, arg.ID, arg.ArrayAppend)
+	_, err := q.db.Exec(ctx, addPermissionToRole, arg.ID, arg.ArrayAppend, arg.OrganizationID)
 	return err
 }
 
+const anonymizeUser = `-- name: AnonymizeUser :execrows
+UPDATE users
+SET username = 'erased-' || id,
+    email = 'erased-' || id || '@erased.invalid',
+    password = '!',
+    first_name = NULL,
+    last_name = NULL,
+    phone_number = NULL,
+    phone_verified = FALSE,
+    sms_two_factor = FALSE,
+    is_active = FALSE,
+    is_verified = FALSE,
+    anonymized_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND anonymized_at IS NULL
+`
+
+type AnonymizeUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) AnonymizeUser(ctx context.Context, arg AnonymizeUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, anonymizeUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const approveUser = `-- name: ApproveUser :execrows
+UPDATE users
+SET approval_pending = FALSE,
+    is_active = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND approval_pending AND deleted_at IS NULL
+`
+
+type ApproveUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) ApproveUser(ctx context.Context, arg ApproveUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, approveUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const createPermission = `-- name: CreatePermission :one
 INSERT INTO permissions (
   name
@@ -64,20 +121,21 @@ func (q *Queries) CreatePermission(ctx context.Context, name string) (Permission
 
 const createRole = `-- name: CreateRole :one
 INSERT INTO roles (
-  role_name, permissions
+  organization_id, role_name, permissions
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 )
-RETURNING id, role_name, permissions, created_at, updated_at, deleted_at
+RETURNING id, role_name, permissions, created_at, updated_at, deleted_at, organization_id
 `
 
 type CreateRoleParams struct {
-	RoleName    string   `json:"role_name"`
-	Permissions []string `json:"permissions"`
+	OrganizationID int32    `json:"organization_id"`
+	RoleName       string   `json:"role_name"`
+	Permissions    []string `json:"permissions"`
 }
 
 func (q *Queries) CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error) {
-	row := q.db.QueryRow(ctx, createRole, arg.RoleName, arg.Permissions)
+	row := q.db.QueryRow(ctx, createRole, arg.OrganizationID, arg.RoleName, arg.Permissions)
 	var i Role
 	err := row.Scan(
 		&i.ID,
@@ -86,33 +144,36 @@ func (q *Queries) CreateRole(ctx context.Context, arg CreateRoleParams) (Role, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-  username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
+  organization_id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
-RETURNING id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at
+RETURNING id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id
 `
 
 type CreateUserParams struct {
-	Username    string      `json:"username"`
-	Email       string      `json:"email"`
-	Password    string      `json:"password"`
-	FirstName   pgtype.Text `json:"first_name"`
-	LastName    pgtype.Text `json:"last_name"`
-	PhoneNumber pgtype.Text `json:"phone_number"`
-	IsActive    pgtype.Bool `json:"is_active"`
-	IsVerified  pgtype.Bool `json:"is_verified"`
-	Role        int64       `json:"role"`
+	OrganizationID int32       `json:"organization_id"`
+	Username       string      `json:"username"`
+	Email          string      `json:"email"`
+	Password       string      `json:"password"`
+	FirstName      pgtype.Text `json:"first_name"`
+	LastName       pgtype.Text `json:"last_name"`
+	PhoneNumber    pgtype.Text `json:"phone_number"`
+	IsActive       pgtype.Bool `json:"is_active"`
+	IsVerified     pgtype.Bool `json:"is_verified"`
+	Role           int64       `json:"role"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
 	row := q.db.QueryRow(ctx, createUser,
+		arg.OrganizationID,
 		arg.Username,
 		arg.Email,
 		arg.Password,
@@ -138,22 +199,109 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
+const createUserIfNotExists = `-- name: CreateUserIfNotExists :exec
+INSERT INTO users (
+  organization_id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateUserIfNotExistsParams struct {
+	OrganizationID int32       `json:"organization_id"`
+	Username       string      `json:"username"`
+	Email          string      `json:"email"`
+	Password       string      `json:"password"`
+	FirstName      pgtype.Text `json:"first_name"`
+	LastName       pgtype.Text `json:"last_name"`
+	PhoneNumber    pgtype.Text `json:"phone_number"`
+	IsActive       pgtype.Bool `json:"is_active"`
+	IsVerified     pgtype.Bool `json:"is_verified"`
+	Role           int64       `json:"role"`
+}
+
+func (q *Queries) CreateUserIfNotExists(ctx context.Context, arg CreateUserIfNotExistsParams) error {
+	_, err := q.db.Exec(ctx, createUserIfNotExists,
+		arg.OrganizationID,
+		arg.Username,
+		arg.Email,
+		arg.Password,
+		arg.FirstName,
+		arg.LastName,
+		arg.PhoneNumber,
+		arg.IsActive,
+		arg.IsVerified,
+		arg.Role,
+	)
+	return err
+}
+
 const deactivateUser = `-- name: DeactivateUser :exec
 UPDATE users
 SET is_active = FALSE,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
 `
 
-func (q *Queries) DeactivateUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deactivateUser, id)
+type DeactivateUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) DeactivateUser(ctx context.Context, arg DeactivateUserParams) error {
+	_, err := q.db.Exec(ctx, deactivateUser, arg.ID, arg.OrganizationID)
 	return err
 }
 
+const getLiveUserByEmail = `-- name: GetLiveUserByEmail :one
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE organization_id = $1 AND email = $2 AND deleted_at IS NULL
+LIMIT 1
+`
+
+type GetLiveUserByEmailParams struct {
+	OrganizationID int32  `json:"organization_id"`
+	Email          string `json:"email"`
+}
+
+func (q *Queries) GetLiveUserByEmail(ctx context.Context, arg GetLiveUserByEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, getLiveUserByEmail, arg.OrganizationID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.FirstName,
+		&i.LastName,
+		&i.PhoneNumber,
+		&i.IsActive,
+		&i.IsVerified,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
 const getPermission = `-- name: GetPermission :one
 
 SELECT id, name, created_at, updated_at, deleted_at FROM permissions
@@ -177,14 +325,45 @@ func (q *Queries) GetPermission(ctx context.Context, id int32) (Permission, erro
 
 const getRole = `-- name: GetRole :one
 
-SELECT id, role_name, permissions, created_at, updated_at, deleted_at FROM roles
-WHERE id = $1 AND deleted_at IS NULL
+SELECT id, role_name, permissions, created_at, updated_at, deleted_at, organization_id FROM roles
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
 LIMIT 1
 `
 
+type GetRoleParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
 // ----------------------ROLES--------------------------------------
-func (q *Queries) GetRole(ctx context.Context, id int32) (Role, error) {
-	row := q.db.QueryRow(ctx, getRole, id)
+func (q *Queries) GetRole(ctx context.Context, arg GetRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, getRole, arg.ID, arg.OrganizationID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.RoleName,
+		&i.Permissions,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getRoleByName = `-- name: GetRoleByName :one
+SELECT id, role_name, permissions, created_at, updated_at, deleted_at, organization_id FROM roles
+WHERE organization_id = $1 AND role_name = $2 AND deleted_at IS NULL
+LIMIT 1
+`
+
+type GetRoleByNameParams struct {
+	OrganizationID int32  `json:"organization_id"`
+	RoleName       string `json:"role_name"`
+}
+
+func (q *Queries) GetRoleByName(ctx context.Context, arg GetRoleByNameParams) (Role, error) {
+	row := q.db.QueryRow(ctx, getRoleByName, arg.OrganizationID, arg.RoleName)
 	var i Role
 	err := row.Scan(
 		&i.ID,
@@ -193,18 +372,67 @@ func (q *Queries) GetRole(ctx context.Context, id int32) (Role, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getTenantUser = `-- name: GetTenantUser :one
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+  AND (organization_id = $2 OR EXISTS (
+    SELECT 1 FROM organization_members
+    WHERE organization_members.user_id = users.id AND organization_members.organization_id = $2
+  ))
+LIMIT 1
+`
+
+type GetTenantUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) GetTenantUser(ctx context.Context, arg GetTenantUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, getTenantUser, arg.ID, arg.OrganizationID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.FirstName,
+		&i.LastName,
+		&i.PhoneNumber,
+		&i.IsActive,
+		&i.IsVerified,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at FROM users
-WHERE id = $1 AND deleted_at IS NULL
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
 LIMIT 1
 `
 
-func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
-	row := q.db.QueryRow(ctx, getUser, id)
+type GetUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) GetUser(ctx context.Context, arg GetUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, arg.ID, arg.OrganizationID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -220,20 +448,74 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at FROM users 
-WHERE email = $1 
-  AND is_verified = true 
-  AND deleted_at IS NULL 
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE email = $2
+  AND is_verified = true
+  AND deleted_at IS NULL
+  AND (organization_id = $1 OR EXISTS (
+    SELECT 1 FROM organization_members
+    WHERE organization_members.user_id = users.id AND organization_members.organization_id = $1
+  ))
+ORDER BY organization_id = $1 DESC, id
 LIMIT 1
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
+type GetUserByEmailParams struct {
+	OrganizationID int32  `json:"organization_id"`
+	Email          string `json:"email"`
+}
+
+func (q *Queries) GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, arg.OrganizationID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.Password,
+		&i.FirstName,
+		&i.LastName,
+		&i.PhoneNumber,
+		&i.IsActive,
+		&i.IsVerified,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const getUserIncludingDeleted = `-- name: GetUserIncludingDeleted :one
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE id = $1 AND organization_id = $2
+LIMIT 1
+`
+
+type GetUserIncludingDeletedParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) GetUserIncludingDeleted(ctx context.Context, arg GetUserIncludingDeletedParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserIncludingDeleted, arg.ID, arg.OrganizationID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -249,10 +531,29 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.PasswordChangedAt,
+		&i.AnonymizedAt,
+		&i.PhoneVerified,
+		&i.SmsTwoFactor,
+		&i.ApprovalPending,
+		&i.OrganizationID,
 	)
 	return i, err
 }
 
+const getUserOrganizationID = `-- name: GetUserOrganizationID :one
+SELECT organization_id FROM users
+WHERE id = $1 AND deleted_at IS NULL
+LIMIT 1
+`
+
+func (q *Queries) GetUserOrganizationID(ctx context.Context, id int32) (int32, error) {
+	row := q.db.QueryRow(ctx, getUserOrganizationID, id)
+	var organizationID int32
+	err := row.Scan(&organizationID)
+	return organizationID, err
+}
+
 const hardDeletePermission = `-- name: HardDeletePermission :exec
 DELETE FROM permissions
 WHERE id = $1
@@ -265,24 +566,192 @@ func (q *Queries) HardDeletePermission(ctx context.Context, id int32) error {
 
 const hardDeleteRole = `-- name: HardDeleteRole :exec
 DELETE FROM roles
-WHERE id = $1
+WHERE id = $1 AND organization_id = $2
 `
 
-func (q *Queries) HardDeleteRole(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, hardDeleteRole, id)
+type HardDeleteRoleParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) HardDeleteRole(ctx context.Context, arg HardDeleteRoleParams) error {
+	_, err := q.db.Exec(ctx, hardDeleteRole, arg.ID, arg.OrganizationID)
 	return err
 }
 
 const hardDeleteUser = `-- name: HardDeleteUser :exec
 DELETE FROM users
-WHERE id = $1
+WHERE id = $1 AND organization_id = $2
 `
 
-func (q *Queries) HardDeleteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, hardDeleteUser, id)
+type HardDeleteUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) HardDeleteUser(ctx context.Context, arg HardDeleteUserParams) error {
+	_, err := q.db.Exec(ctx, hardDeleteUser, arg.ID, arg.OrganizationID)
 	return err
 }
 
+const listDeletedPermissions = `-- name: ListDeletedPermissions :many
+SELECT id, name, created_at, updated_at, deleted_at FROM permissions
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) ListDeletedPermissions(ctx context.Context) ([]Permission, error) {
+	rows, err := q.db.Query(ctx, listDeletedPermissions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Permission
+	for rows.Next() {
+		var i Permission
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedRoles = `-- name: ListDeletedRoles :many
+SELECT id, role_name, permissions, created_at, updated_at, deleted_at, organization_id FROM roles
+WHERE organization_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) ListDeletedRoles(ctx context.Context, organizationID int32) ([]Role, error) {
+	rows, err := q.db.Query(ctx, listDeletedRoles, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Role
+	for rows.Next() {
+		var i Role
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoleName,
+			&i.Permissions,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedUsers = `-- name: ListDeletedUsers :many
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE organization_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) ListDeletedUsers(ctx context.Context, organizationID int32) ([]User, error) {
+	rows, err := q.db.Query(ctx, listDeletedUsers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.Password,
+			&i.FirstName,
+			&i.LastName,
+			&i.PhoneNumber,
+			&i.IsActive,
+			&i.IsVerified,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.PasswordChangedAt,
+			&i.AnonymizedAt,
+			&i.PhoneVerified,
+			&i.SmsTwoFactor,
+			&i.ApprovalPending,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingApprovalUsers = `-- name: ListPendingApprovalUsers :many
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE organization_id = $1 AND approval_pending AND deleted_at IS NULL
+ORDER BY created_at
+`
+
+func (q *Queries) ListPendingApprovalUsers(ctx context.Context, organizationID int32) ([]User, error) {
+	rows, err := q.db.Query(ctx, listPendingApprovalUsers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.Password,
+			&i.FirstName,
+			&i.LastName,
+			&i.PhoneNumber,
+			&i.IsActive,
+			&i.IsVerified,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.PasswordChangedAt,
+			&i.AnonymizedAt,
+			&i.PhoneVerified,
+			&i.SmsTwoFactor,
+			&i.ApprovalPending,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPermissions = `-- name: ListPermissions :many
 SELECT id, name, created_at, updated_at, deleted_at FROM permissions
 WHERE deleted_at IS NULL
@@ -316,13 +785,13 @@ func (q *Queries) ListPermissions(ctx context.Context) ([]Permission, error) {
 }
 
 const listRoles = `-- name: ListRoles :many
-SELECT id, role_name, permissions, created_at, updated_at, deleted_at FROM roles
-WHERE deleted_at IS NULL
+SELECT id, role_name, permissions, created_at, updated_at, deleted_at, organization_id FROM roles
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY role_name
 `
 
-func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
-	rows, err := q.db.Query(ctx, listRoles)
+func (q *Queries) ListRoles(ctx context.Context, organizationID int32) ([]Role, error) {
+	rows, err := q.db.Query(ctx, listRoles, organizationID)
 	if err != nil {
 		return nil, err
 	}
@@ -337,6 +806,7 @@ func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.OrganizationID,
 		); err != nil {
 			return nil, err
 		}
@@ -349,13 +819,13 @@ func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at FROM users
-WHERE deleted_at IS NULL
+SELECT id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id FROM users
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY username
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+func (q *Queries) ListUsers(ctx context.Context, organizationID int32) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers, organizationID)
 	if err != nil {
 		return nil, err
 	}
@@ -377,6 +847,12 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.PasswordChangedAt,
+			&i.AnonymizedAt,
+			&i.PhoneVerified,
+			&i.SmsTwoFactor,
+			&i.ApprovalPending,
+			&i.OrganizationID,
 		); err != nil {
 			return nil, err
 		}
@@ -388,20 +864,174 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const purgeDeletedPermissions = `-- name: PurgeDeletedPermissions :execrows
+DELETE FROM permissions
+WHERE deleted_at < NOW() - $1::interval
+`
+
+func (q *Queries) PurgeDeletedPermissions(ctx context.Context, retention pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedPermissions, retention)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDeletedRoles = `-- name: PurgeDeletedRoles :execrows
+DELETE FROM roles
+WHERE deleted_at < NOW() - $1::interval
+  AND NOT EXISTS (SELECT 1 FROM users WHERE users.role = roles.id)
+  AND NOT EXISTS (SELECT 1 FROM organization_members WHERE organization_members.role = roles.id)
+`
+
+func (q *Queries) PurgeDeletedRoles(ctx context.Context, retention pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedRoles, retention)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() - $1::interval
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, retention pgtype.Interval) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedUsers, retention)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rejectUser = `-- name: RejectUser :execrows
+UPDATE users
+SET approval_pending = FALSE,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND approval_pending AND deleted_at IS NULL
+`
+
+type RejectUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) RejectUser(ctx context.Context, arg RejectUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rejectUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const removePermissionFromRole = `-- name: RemovePermissionFromRole :exec
 UPDATE roles
 SET permissions = array_remove(permissions, $2),
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
 `
 
 type RemovePermissionFromRoleParams struct {
-	ID          int32       `json:"id"`
-	ArrayRemove interface{} `json:"array_remove"`
+	ID             int32       `json:"id"`
+	ArrayRemove    interface{} `json:"array_remove"`
+	OrganizationID int32       `json:"organization_id"`
 }
 
 func (q *Queries) RemovePermissionFromRole(ctx context.Context, arg RemovePermissionFromRoleParams) error {
-	_, err := q.db.Exec(ctx, removePermissionFromRole, arg.ID, arg.ArrayRemove)
+	_, err := q.db.Exec(ctx, removePermissionFromRole, arg.ID, arg.ArrayRemove, arg.OrganizationID)
+	return err
+}
+
+const requireUserApproval = `-- name: RequireUserApproval :exec
+UPDATE users
+SET approval_pending = TRUE,
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
+`
+
+type RequireUserApprovalParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) RequireUserApproval(ctx context.Context, arg RequireUserApprovalParams) error {
+	_, err := q.db.Exec(ctx, requireUserApproval, arg.ID, arg.OrganizationID)
+	return err
+}
+
+const restorePermission = `-- name: RestorePermission :execrows
+UPDATE permissions
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) RestorePermission(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, restorePermission, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreRole = `-- name: RestoreRole :execrows
+UPDATE roles
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NOT NULL
+`
+
+type RestoreRoleParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) RestoreRole(ctx context.Context, arg RestoreRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreRole, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NOT NULL
+`
+
+type RestoreUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setUserSmsTwoFactor = `-- name: SetUserSmsTwoFactor :exec
+UPDATE users
+SET sms_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
+`
+
+type SetUserSmsTwoFactorParams struct {
+	ID             int32       `json:"id"`
+	SmsTwoFactor   pgtype.Bool `json:"sms_two_factor"`
+	OrganizationID int32       `json:"organization_id"`
+}
+
+func (q *Queries) SetUserSmsTwoFactor(ctx context.Context, arg SetUserSmsTwoFactorParams) error {
+	_, err := q.db.Exec(ctx, setUserSmsTwoFactor, arg.ID, arg.SmsTwoFactor, arg.OrganizationID)
 	return err
 }
 
@@ -419,22 +1049,32 @@ func (q *Queries) SoftDeletePermission(ctx context.Context, id int32) error {
 const softDeleteRole = `-- name: SoftDeleteRole :exec
 UPDATE roles
 SET deleted_at = NOW()
-WHERE id = $1
+WHERE id = $1 AND organization_id = $2
 `
 
-func (q *Queries) SoftDeleteRole(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, softDeleteRole, id)
+type SoftDeleteRoleParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) SoftDeleteRole(ctx context.Context, arg SoftDeleteRoleParams) error {
+	_, err := q.db.Exec(ctx, softDeleteRole, arg.ID, arg.OrganizationID)
 	return err
 }
 
 const softDeleteUser = `-- name: SoftDeleteUser :exec
 UPDATE users
 SET deleted_at = NOW()
-WHERE id = $1
+WHERE id = $1 AND organization_id = $2
 `
 
-func (q *Queries) SoftDeleteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, softDeleteUser, id)
+type SoftDeleteUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, arg SoftDeleteUserParams) error {
+	_, err := q.db.Exec(ctx, softDeleteUser, arg.ID, arg.OrganizationID)
 	return err
 }
 
@@ -460,61 +1100,245 @@ UPDATE roles
 SET role_name = $2,
     permissions = $3,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $4 AND deleted_at IS NULL
 `
 
 type UpdateRoleParams struct {
-	ID          int32    `json:"id"`
-	RoleName    string   `json:"role_name"`
-	Permissions []string `json:"permissions"`
+	ID             int32    `json:"id"`
+	RoleName       string   `json:"role_name"`
+	Permissions    []string `json:"permissions"`
+	OrganizationID int32    `json:"organization_id"`
 }
 
 func (q *Queries) UpdateRole(ctx context.Context, arg UpdateRoleParams) error {
-	_, err := q.db.Exec(ctx, updateRole, arg.ID, arg.RoleName, arg.Permissions)
+	_, err := q.db.Exec(ctx, updateRole,
+		arg.ID,
+		arg.RoleName,
+		arg.Permissions,
+		arg.OrganizationID,
+	)
 	return err
 }
 
 const updateUser = `-- name: UpdateUser :exec
 UPDATE users
 SET username = $2,
-    email = $3,
-    password = $4,
-    first_name = $5,
-    last_name = $6,
-    phone_number = $7,
-    is_active = $8,
-    is_verified = $9,
-    role = $10,
+    first_name = $3,
+    last_name = $4,
+    phone_number = $5,
+    phone_verified = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE phone_verified END,
+    sms_two_factor = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE sms_two_factor END,
+    is_active = $6,
+    is_verified = $7,
+    role = $8,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
-RETURNING id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at
+WHERE id = $1 AND organization_id = $9 AND deleted_at IS NULL
+RETURNING id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role, created_at, updated_at, deleted_at, password_changed_at, anonymized_at, phone_verified, sms_two_factor, approval_pending, organization_id
 `
 
 type UpdateUserParams struct {
-	ID          int32       `json:"id"`
-	Username    string      `json:"username"`
-	Email       string      `json:"email"`
-	Password    string      `json:"password"`
-	FirstName   pgtype.Text `json:"first_name"`
-	LastName    pgtype.Text `json:"last_name"`
-	PhoneNumber pgtype.Text `json:"phone_number"`
-	IsActive    pgtype.Bool `json:"is_active"`
-	IsVerified  pgtype.Bool `json:"is_verified"`
-	Role        int64       `json:"role"`
+	ID             int32       `json:"id"`
+	Username       string      `json:"username"`
+	FirstName      pgtype.Text `json:"first_name"`
+	LastName       pgtype.Text `json:"last_name"`
+	PhoneNumber    pgtype.Text `json:"phone_number"`
+	IsActive       pgtype.Bool `json:"is_active"`
+	IsVerified     pgtype.Bool `json:"is_verified"`
+	Role           int64       `json:"role"`
+	OrganizationID int32       `json:"organization_id"`
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 	_, err := q.db.Exec(ctx, updateUser,
 		arg.ID,
 		arg.Username,
-		arg.Email,
-		arg.Password,
 		arg.FirstName,
 		arg.LastName,
 		arg.PhoneNumber,
 		arg.IsActive,
 		arg.IsVerified,
 		arg.Role,
+		arg.OrganizationID,
+	)
+	return err
+}
+
+const updateUserEmail = `-- name: UpdateUserEmail :exec
+UPDATE users
+SET email = $2,
+    is_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
+`
+
+type UpdateUserEmailParams struct {
+	ID             int32  `json:"id"`
+	Email          string `json:"email"`
+	OrganizationID int32  `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) error {
+	_, err := q.db.Exec(ctx, updateUserEmail, arg.ID, arg.Email, arg.OrganizationID)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = $2,
+    password_changed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
+`
+
+type UpdateUserPasswordParams struct {
+	ID             int32  `json:"id"`
+	Password       string `json:"password"`
+	OrganizationID int32  `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.ID, arg.Password, arg.OrganizationID)
+	return err
+}
+
+const updateUserPasswordHash = `-- name: UpdateUserPasswordHash :exec
+UPDATE users
+SET password = $2
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL
+`
+
+type UpdateUserPasswordHashParams struct {
+	ID             int32  `json:"id"`
+	Password       string `json:"password"`
+	OrganizationID int32  `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserPasswordHash(ctx context.Context, arg UpdateUserPasswordHashParams) error {
+	_, err := q.db.Exec(ctx, updateUserPasswordHash, arg.ID, arg.Password, arg.OrganizationID)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :exec
+UPDATE users
+SET username = $2,
+    first_name = $3,
+    last_name = $4,
+    phone_number = $5,
+    phone_verified = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE phone_verified END,
+    sms_two_factor = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE sms_two_factor END,
+    is_active = $6,
+    role = $7,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $8 AND deleted_at IS NULL
+`
+
+type UpdateUserProfileParams struct {
+	ID             int32       `json:"id"`
+	Username       string      `json:"username"`
+	FirstName      pgtype.Text `json:"first_name"`
+	LastName       pgtype.Text `json:"last_name"`
+	PhoneNumber    pgtype.Text `json:"phone_number"`
+	IsActive       pgtype.Bool `json:"is_active"`
+	Role           int64       `json:"role"`
+	OrganizationID int32       `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error {
+	_, err := q.db.Exec(ctx, updateUserProfile,
+		arg.ID,
+		arg.Username,
+		arg.FirstName,
+		arg.LastName,
+		arg.PhoneNumber,
+		arg.IsActive,
+		arg.Role,
+		arg.OrganizationID,
+	)
+	return err
+}
+
+const upsertPermission = `-- name: UpsertPermission :exec
+INSERT INTO permissions (
+  name
+) VALUES (
+  $1
+)
+ON CONFLICT (name) WHERE deleted_at IS NULL DO UPDATE
+SET updated_at = NOW()
+`
+
+func (q *Queries) UpsertPermission(ctx context.Context, name string) error {
+	_, err := q.db.Exec(ctx, upsertPermission, name)
+	return err
+}
+
+const upsertRole = `-- name: UpsertRole :one
+INSERT INTO roles (
+  organization_id, role_name, permissions
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (organization_id, role_name) WHERE deleted_at IS NULL DO UPDATE
+SET permissions = EXCLUDED.permissions,
+    updated_at = NOW()
+RETURNING id, role_name, permissions, created_at, updated_at, deleted_at, organization_id
+`
+
+type UpsertRoleParams struct {
+	OrganizationID int32    `json:"organization_id"`
+	RoleName       string   `json:"role_name"`
+	Permissions    []string `json:"permissions"`
+}
+
+func (q *Queries) UpsertRole(ctx context.Context, arg UpsertRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, upsertRole, arg.OrganizationID, arg.RoleName, arg.Permissions)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.RoleName,
+		&i.Permissions,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.OrganizationID,
 	)
+	return i, err
+}
+
+const verifyUser = `-- name: VerifyUser :exec
+UPDATE users
+SET is_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
+`
+
+type VerifyUserParams struct {
+	ID             int32 `json:"id"`
+	OrganizationID int32 `json:"organization_id"`
+}
+
+func (q *Queries) VerifyUser(ctx context.Context, arg VerifyUserParams) error {
+	_, err := q.db.Exec(ctx, verifyUser, arg.ID, arg.OrganizationID)
 	return err
 }
+
+const verifyUserPhone = `-- name: VerifyUserPhone :execrows
+UPDATE users
+SET phone_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND phone_number = $2 AND organization_id = $3 AND deleted_at IS NULL
+`
+
+type VerifyUserPhoneParams struct {
+	ID             int32       `json:"id"`
+	PhoneNumber    pgtype.Text `json:"phone_number"`
+	OrganizationID int32       `json:"organization_id"`
+}
+
+func (q *Queries) VerifyUserPhone(ctx context.Context, arg VerifyUserPhoneParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyUserPhone, arg.ID, arg.PhoneNumber, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
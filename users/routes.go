@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Route declares an endpoint together with the permission it requires. An
+// empty permission means any authenticated caller, Public skips authentication.
+type Route struct {
+	Method     string
+	Path       string
+	Handler    echo.HandlerFunc
+	Permission string
+	Public     bool
+}
+
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type PermissionRoutes struct {
+	Permission string      `json:"permission"`
+	Routes     []RouteInfo `json:"routes"`
+}
+
+// RouteRegistry is the single source of truth for which permission guards
+// which route
+type RouteRegistry struct {
+	prefix string
+	routes []Route
+}
+
+func NewRouteRegistry(prefix string, routes []Route) *RouteRegistry {
+	return &RouteRegistry{prefix: prefix, routes: routes}
+}
+
+// Validate fails when a route requires a permission that is not known
+func (r *RouteRegistry) Validate(known []string) error {
+	for _, route := range r.routes {
+		if route.Permission != "" && !slices.Contains(known, route.Permission) {
+			return fmt.Errorf("route %s %s requires unknown permission %s", route.Method, r.prefix+route.Path, route.Permission)
+		}
+	}
+	return nil
+}
+
+// Register mounts every route on the group with tenant resolution,
+// authentication and the permission check it declares. The tenant is resolved
+// first so credentials can be checked against it.
+func (r *RouteRegistry) Register(g *echo.Group, resolveTenant, authenticate echo.MiddlewareFunc) {
+	for _, route := range r.routes {
+		middlewares := []echo.MiddlewareFunc{resolveTenant}
+		if !route.Public {
+			middlewares = append(middlewares, authenticate)
+		}
+		if route.Permission != "" {
+			middlewares = append(middlewares, Has(route.Permission))
+		}
+		g.Add(route.Method, route.Path, route.Handler, middlewares...)
+	}
+}
+
+// Match finds the route serving method and path, the path may carry the
+// prefix and a query. Static segments win over parameters, so
+// /users/pending-approval is not taken for /users/:id.
+func (r *RouteRegistry) Match(method, path string) (Route, bool) {
+	path, _, _ = strings.Cut(path, "?")
+	path = strings.TrimPrefix(path, r.prefix)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var match Route
+	found, fewestParams := false, 0
+	for _, route := range r.routes {
+		if !strings.EqualFold(route.Method, method) {
+			continue
+		}
+
+		routeSegments := strings.Split(strings.Trim(route.Path, "/"), "/")
+		if len(routeSegments) != len(segments) {
+			continue
+		}
+
+		params, ok := 0, true
+		for i, segment := range routeSegments {
+			if strings.HasPrefix(segment, ":") && segments[i] != "" {
+				params++
+			} else if segment != segments[i] {
+				ok = false
+				break
+			}
+		}
+		if ok && (!found || params < fewestParams) {
+			match, found, fewestParams = route, true, params
+		}
+	}
+	return match, found
+}
+
+// RoutesByPermission lists the routes each known permission unlocks
+func (r *RouteRegistry) RoutesByPermission(known []string) []PermissionRoutes {
+	result := make([]PermissionRoutes, 0)
+	for _, permission := range known {
+		routes := make([]RouteInfo, 0)
+		for _, route := range r.routes {
+			if route.Permission == permission {
+				routes = append(routes, RouteInfo{Method: route.Method, Path: r.prefix + route.Path})
+			}
+		}
+		result = append(result, PermissionRoutes{Permission: permission, Routes: routes})
+	}
+	return result
+}
+
+func (h *AuthHandler) GetPermissionRoutes(c echo.Context) error {
+	return NewResponse(c, "success", h.Routes.RoutesByPermission(KnownPermissions), "", http.StatusOK)
+}
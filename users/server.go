@@ -4,26 +4,31 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 	"users/repository"
 
-	"github.com/go-playground/validator"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
 	Echo       *echo.Echo
-	DB         *pgx.Conn
+	DB         *pgxpool.Pool
 	Logger     *slog.Logger
 	Cfg        *Config
 	Ctx        context.Context
 	ShutdownCh chan os.Signal
 	Server     *http.Server
+	Grpc       *grpc.Server
+	Passwords  *PasswordPolicy
+	Events     EventPublisher
+	StopJobs   context.CancelFunc
 }
 
 func NewServer() (*Server, error) {
@@ -35,20 +40,30 @@ func NewServer() (*Server, error) {
 	cfg := LoadConfig()
 
 	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, fmt.Sprintf("user=%s password=%s dbname=%s host =%s port=%s", cfg.DbUser, cfg.DbPassword, cfg.DbName, cfg.DbHost, cfg.DbPort))
+	conn, err := pgxpool.New(ctx, fmt.Sprintf("user=%s password=%s dbname=%s host =%s port=%s", cfg.DbUser, cfg.DbPassword, cfg.DbName, cfg.DbHost, cfg.DbPort))
 	if err != nil {
 		panic(err)
 	}
 	logger.Info("database connection established")
 
-	repo := repository.New(conn)
-	err = CreatePermissionsSeed(repo)
+	// a policy without its breached list would quietly accept breached passwords
+	passwords, err := NewPasswordPolicy(cfg)
 	if err != nil {
-		logger.Error("failed to create permissions seed: ", "error", err)
+		logger.Error("failed to load breached passwords: ", "error", err)
+		os.Exit(1)
 	}
-	err = CreateSuperAdmin(repo)
+
+	// serving without the seeded permissions and roles would refuse every request
+	manifest, err := LoadManifest(cfg.BootstrapManifest, KnownPermissions)
+	if err != nil {
+		logger.Error("failed to load bootstrap manifest: ", "error", err)
+		os.Exit(1)
+	}
+
+	err = Bootstrap(ctx, conn, cfg, logger, manifest, NewPasswordHasher(cfg), passwords)
 	if err != nil {
-		logger.Error("failed to create super admin: ", "error", err)
+		logger.Error("failed to bootstrap permissions and superadmin: ", "error", err)
+		os.Exit(1)
 	}
 
 	e := echo.New()
@@ -59,12 +74,27 @@ func NewServer() (*Server, error) {
 		Cfg:        cfg,
 		Ctx:        ctx,
 		ShutdownCh: make(chan os.Signal, 1),
+		Passwords:  passwords,
 	}
 	signal.Notify(server.ShutdownCh, os.Interrupt)
 	return server, nil
 }
 
 func (s *Server) Start() {
+	// routes are validated before we start accepting requests
+	if err := s.SetupRouter(); err != nil {
+		s.Logger.Error("Failed to setup router: ", "error", err)
+		os.Exit(1)
+	}
+
+	// events would be lost if they were relayed to a publisher that cannot work
+	events, err := NewEventPublisher(s.Cfg, s.Logger)
+	if err != nil {
+		s.Logger.Error("Failed to setup event publisher: ", "error", err)
+		os.Exit(1)
+	}
+	s.Events = events
+
 	s.Server = &http.Server{
 		Addr:    s.Cfg.AppAddr,
 		Handler: s.Echo,
@@ -77,7 +107,60 @@ func (s *Server) Start() {
 	}()
 
 	s.Logger.Info("Server running at: " + s.Cfg.AppAddr)
-	s.SetupRouter()
+
+	listener, err := net.Listen("tcp", s.Cfg.GrpcAddr)
+	if err != nil {
+		s.Logger.Error("Failed to listen: ", "error", err)
+		os.Exit(1)
+	}
+	s.Grpc = NewGrpcServer(&GrpcServer{
+		Repo:   repository.New(s.DB),
+		Cfg:    s.Cfg,
+		Logger: s.Logger,
+	})
+
+	go func() {
+		if err := s.Grpc.Serve(listener); err != nil {
+			s.Logger.Error("Failed to serve grpc: ", "error", err)
+		}
+	}()
+
+	s.Logger.Info("gRPC server running at: " + s.Cfg.GrpcAddr)
+
+	// background jobs
+	jobsCtx, stopJobs := context.WithCancel(s.Ctx)
+	s.StopJobs = stopJobs
+	purge := &PurgeJob{
+		Repo:      repository.New(s.DB),
+		Logger:    s.Logger,
+		Retention: s.Cfg.SoftDeleteRetention,
+		Interval:  s.Cfg.PurgeInterval,
+	}
+	go purge.Run(jobsCtx)
+	relay := &OutboxRelay{
+		DB:         s.DB,
+		Repo:       repository.New(s.DB),
+		Publisher:  s.Events,
+		Logger:     s.Logger,
+		Interval:   s.Cfg.OutboxInterval,
+		BatchSize:  s.Cfg.OutboxBatchSize,
+		MaxBackoff: s.Cfg.OutboxMaxBackoff,
+		Retention:  s.Cfg.OutboxRetention,
+	}
+	go relay.Run(jobsCtx)
+	webhooks := &WebhookDispatcher{
+		DB:          s.DB,
+		Repo:        repository.New(s.DB),
+		Client:      NewWebhookClient(s.Cfg.WebhookTimeout, s.Cfg.WebhookAllowPrivate),
+		Logger:      s.Logger,
+		Interval:    s.Cfg.WebhookInterval,
+		BatchSize:   s.Cfg.WebhookBatchSize,
+		MaxAttempts: s.Cfg.WebhookMaxAttempts,
+		RetryBase:   s.Cfg.WebhookRetryBase,
+		MaxBackoff:  s.Cfg.WebhookMaxBackoff,
+	}
+	go webhooks.Run(jobsCtx)
+
 	<-s.ShutdownCh
 	s.Shutdown()
 }
@@ -93,15 +176,33 @@ func (s *Server) Shutdown() {
 		s.Logger.Error("Server forced to shutdown: ", "error", err)
 	}
 
-	// Close database connection
-	if err := s.DB.Close(ctx); err != nil {
-		s.Logger.Error("Failed to disconnect database: ", "error", err)
+	// let in-flight grpc calls finish within the same deadline
+	if s.Grpc != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.Grpc.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.Grpc.Stop()
+			s.Logger.Error("gRPC server forced to shutdown")
+		}
 	}
 
+	// Stop background jobs
+	if s.StopJobs != nil {
+		s.StopJobs()
+	}
+
+	// Close database connection
+	s.DB.Close()
+
 	s.Logger.Info("Shutdown completed successfully")
 }
 
-func (s *Server) SetupRouter() {
+func (s *Server) SetupRouter() error {
 	// Middlewares
 	s.Echo.Use(middleware.CORSWithConfig(Cors()))
 	s.Echo.Use(middleware.Secure())
@@ -127,45 +228,141 @@ func (s *Server) SetupRouter() {
 			return nil
 		},
 	}))
-	s.Echo.Validator = &CustomValidator{Validator: validator.New()}
+	s.Echo.Validator = NewValidator()
+	s.Echo.HTTPErrorHandler = NewHTTPErrorHandler(s.Logger)
+
+	sms, err := NewSmsSender(s.Cfg, s.Logger)
+	if err != nil {
+		return err
+	}
+
+	auth := &AuthHandler{
+		DB:        s.DB,
+		Repo:      repository.New(s.DB),
+		Logger:    s.Logger,
+		Cfg:       s.Cfg,
+		Ctx:       s.Ctx,
+		Passwords: s.Passwords,
+		Hasher:    NewPasswordHasher(s.Cfg),
+		Sms:       sms,
+	}
+
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/health", Handler: Health, Public: true},
+		{Method: http.MethodGet, Path: "/openapi.json", Handler: auth.GetOpenAPI, Public: true},
+		{Method: http.MethodGet, Path: "/events/schemas", Handler: auth.ListEventSchemas, Public: true},
+		{Method: http.MethodGet, Path: "/verify-email", Handler: auth.VerifyEmail, Public: true},
+		{Method: http.MethodPost, Path: "/register", Handler: auth.Register, Public: true},
+		{Method: http.MethodPost, Path: "/login", Handler: auth.Login, Public: true},
+		{Method: http.MethodPost, Path: "/login/verify", Handler: auth.VerifyLogin, Public: true},
+		{Method: http.MethodPost, Path: "/forgot-password", Handler: auth.ForgotPassword, Public: true},
+		{Method: http.MethodPost, Path: "/reset-password", Handler: auth.ResetPassword, Public: true},
+		{Method: http.MethodPost, Path: "/refresh-token", Handler: auth.RefreshToken, Public: true},
+		{Method: http.MethodPost, Path: "/invitations/accept", Handler: auth.AcceptInvitation, Public: true},
+		{Method: http.MethodPost, Path: "/authorize", Handler: auth.Authorize, Public: true},
+		{Method: http.MethodPost, Path: "/authorize/batch", Handler: auth.AuthorizeBatch, Public: true},
+		{Method: http.MethodGet, Path: "/email-change/confirm", Handler: auth.ConfirmEmailChange, Public: true},
+		{Method: http.MethodGet, Path: "/email-change/cancel", Handler: auth.CancelEmailChange, Public: true},
+
+		{Method: http.MethodGet, Path: "/logout", Handler: auth.Logout},
+
+		{Method: http.MethodGet, Path: "/me/export", Handler: auth.ExportMyData},
+		{Method: http.MethodGet, Path: "/me/email", Handler: auth.GetMyEmailChange},
+		{Method: http.MethodPost, Path: "/me/email", Handler: auth.ChangeMyEmail},
+		{Method: http.MethodPost, Path: "/me/phone/verify", Handler: auth.RequestPhoneVerification},
+		{Method: http.MethodPost, Path: "/me/phone/confirm", Handler: auth.ConfirmPhoneVerification},
+		{Method: http.MethodPut, Path: "/me/two-factor", Handler: auth.SetSmsTwoFactor},
+		{Method: http.MethodGet, Path: "/me/sessions", Handler: auth.ListMySessions},
+		{Method: http.MethodGet, Path: "/me/organizations", Handler: auth.ListMyOrganizations},
+		{Method: http.MethodPost, Path: "/me/organizations", Handler: auth.JoinOrganization},
+		{Method: http.MethodGet, Path: "/me/groups", Handler: auth.ListMyGroups},
+		{Method: http.MethodDelete, Path: "/me/sessions/:id", Handler: auth.RevokeMySession},
+		{Method: http.MethodGet, Path: "/users/:id/sessions", Handler: auth.ListUserSessions, Permission: SessionsListPermission},
+		{Method: http.MethodDelete, Path: "/users/:id/sessions", Handler: auth.RevokeUserSessions, Permission: SessionsDeletePermission},
+		{Method: http.MethodDelete, Path: "/users/:id/sessions/:sessionId", Handler: auth.RevokeUserSession, Permission: SessionsDeletePermission},
+
+		{Method: http.MethodGet, Path: "/api-keys", Handler: auth.ListApiKeys},
+		{Method: http.MethodPost, Path: "/api-keys", Handler: auth.CreateApiKey},
+		{Method: http.MethodDelete, Path: "/api-keys/:id", Handler: auth.RevokeApiKey},
+
+		{Method: http.MethodGet, Path: "/invitations", Handler: auth.ListInvitations, Permission: InvitationsListPermission},
+		{Method: http.MethodPost, Path: "/invitations", Handler: auth.CreateInvitation, Permission: InvitationsCreatePermission},
+		{Method: http.MethodPost, Path: "/invitations/:id/resend", Handler: auth.ResendInvitation, Permission: InvitationsCreatePermission},
+		{Method: http.MethodDelete, Path: "/invitations/:id", Handler: auth.RevokeInvitation, Permission: InvitationsDeletePermission},
+
+		{Method: http.MethodGet, Path: "/webhooks", Handler: auth.ListWebhooks, Permission: WebhooksListPermission},
+		{Method: http.MethodGet, Path: "/webhooks/:id", Handler: auth.GetWebhook, Permission: WebhooksListPermission},
+		{Method: http.MethodPost, Path: "/webhooks", Handler: auth.CreateWebhook, Permission: WebhooksCreatePermission},
+		{Method: http.MethodPut, Path: "/webhooks/:id", Handler: auth.UpdateWebhook, Permission: WebhooksUpdatePermission},
+		{Method: http.MethodPost, Path: "/webhooks/:id/secret", Handler: auth.RotateWebhookSecret, Permission: WebhooksUpdatePermission},
+		{Method: http.MethodDelete, Path: "/webhooks/:id", Handler: auth.DeleteWebhook, Permission: WebhooksDeletePermission},
+		{Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Handler: auth.ListWebhookDeliveries, Permission: WebhooksListPermission},
+		{Method: http.MethodGet, Path: "/webhooks/:id/deliveries/:deliveryId", Handler: auth.GetWebhookDelivery, Permission: WebhooksListPermission},
+		{Method: http.MethodPost, Path: "/webhooks/:id/deliveries/:deliveryId/redeliver", Handler: auth.RedeliverWebhook, Permission: WebhooksUpdatePermission},
+
+		{Method: http.MethodGet, Path: "/permissions", Handler: auth.GetAllPermissions, Permission: PermissionsListPermission},
+		{Method: http.MethodGet, Path: "/permissions/routes", Handler: auth.GetPermissionRoutes, Permission: PermissionsListPermission},
+		{Method: http.MethodPost, Path: "/permissions", Handler: auth.CreatePermissions, Permission: PermissionsCreatePermission},
+		{Method: http.MethodDelete, Path: "/permissions/:id", Handler: auth.DeletePermissions, Permission: PermissionsDeletePermission},
+		{Method: http.MethodPost, Path: "/permissions/:id/restore", Handler: auth.RestorePermissions, Permission: PermissionsRestorePermission},
+
+		{Method: http.MethodGet, Path: "/roles", Handler: auth.GetAllRoles, Permission: RolesListPermission},
+		{Method: http.MethodGet, Path: "/roles/:id", Handler: auth.GetOneRole, Permission: RolesReadPermission},
+		{Method: http.MethodPost, Path: "/roles", Handler: auth.CreateRoles, Permission: RolesCreatePermission},
+		{Method: http.MethodPut, Path: "/roles/:id", Handler: auth.UpdateRoles, Permission: RolesUpdatePermission},
+		{Method: http.MethodDelete, Path: "/roles/:id", Handler: auth.DeleteRoles, Permission: RolesDeletePermission},
+		{Method: http.MethodPost, Path: "/roles/:id/restore", Handler: auth.RestoreRoles, Permission: RolesRestorePermission},
+
+		{Method: http.MethodGet, Path: "/users", Handler: auth.GetAllUsers, Permission: UsersListPermission},
+		{Method: http.MethodGet, Path: "/users/:id", Handler: auth.GetOneUser, Permission: UsersReadPermission},
+		{Method: http.MethodPost, Path: "/users", Handler: auth.CreateUsers, Permission: UsersCreatePermission},
+		{Method: http.MethodPut, Path: "/users/:id", Handler: auth.UpdateUsers, Permission: UsersUpdatePermission},
+		{Method: http.MethodDelete, Path: "/users/:id", Handler: auth.DeleteUsers, Permission: UsersDeletePermission},
+		{Method: http.MethodPost, Path: "/users/import", Handler: auth.ImportUsers, Permission: UsersImportPermission},
+		{Method: http.MethodGet, Path: "/users/export", Handler: auth.ExportUsers, Permission: UsersExportPermission},
+		{Method: http.MethodGet, Path: "/users/pending-approval", Handler: auth.ListPendingApprovals, Permission: UsersApprovePermission},
+		{Method: http.MethodPost, Path: "/users/:id/approve", Handler: auth.ApproveUser, Permission: UsersApprovePermission},
+		{Method: http.MethodPost, Path: "/users/:id/reject", Handler: auth.RejectUser, Permission: UsersApprovePermission},
+		{Method: http.MethodPost, Path: "/users/:id/restore", Handler: auth.RestoreUsers, Permission: UsersRestorePermission},
+		{Method: http.MethodPost, Path: "/users/:id/email", Handler: auth.ChangeUserEmail, Permission: UsersUpdatePermission},
+		{Method: http.MethodGet, Path: "/users/:id/export", Handler: auth.ExportUser, Permission: UsersExportPermission},
+		{Method: http.MethodPost, Path: "/users/:id/erase", Handler: auth.EraseUser, Permission: UsersErasePermission},
+		{Method: http.MethodGet, Path: "/users/:id/groups", Handler: auth.ListUserGroups, Permission: GroupsListPermission},
+
+		{Method: http.MethodGet, Path: "/groups", Handler: auth.ListGroups, Permission: GroupsListPermission},
+		{Method: http.MethodGet, Path: "/groups/:id", Handler: auth.GetGroup, Permission: GroupsListPermission},
+		{Method: http.MethodPost, Path: "/groups", Handler: auth.CreateGroup, Permission: GroupsCreatePermission},
+		{Method: http.MethodPut, Path: "/groups/:id", Handler: auth.RenameGroup, Permission: GroupsUpdatePermission},
+		{Method: http.MethodDelete, Path: "/groups/:id", Handler: auth.DeleteGroup, Permission: GroupsDeletePermission},
+		{Method: http.MethodGet, Path: "/groups/:id/members", Handler: auth.ListGroupMembers, Permission: GroupsListPermission},
+		{Method: http.MethodPut, Path: "/groups/:id/members/:userId", Handler: auth.AddGroupMember, Permission: GroupsMembersPermission},
+		{Method: http.MethodDelete, Path: "/groups/:id/members/:userId", Handler: auth.RemoveGroupMember, Permission: GroupsMembersPermission},
+		{Method: http.MethodPut, Path: "/groups/:id/roles/:roleId", Handler: auth.GrantGroupRole, Permission: GroupsRolesPermission},
+		{Method: http.MethodDelete, Path: "/groups/:id/roles/:roleId", Handler: auth.RevokeGroupRole, Permission: GroupsRolesPermission},
+
+		{Method: http.MethodGet, Path: "/organizations", Handler: auth.ListOrganizations, Permission: OrganizationsListPermission},
+		{Method: http.MethodGet, Path: "/organizations/:id", Handler: auth.GetOrganization, Permission: OrganizationsListPermission},
+		{Method: http.MethodPost, Path: "/organizations", Handler: auth.CreateOrganization, Permission: OrganizationsCreatePermission},
+		{Method: http.MethodPut, Path: "/organizations/:id", Handler: auth.UpdateOrganization, Permission: OrganizationsUpdatePermission},
+		{Method: http.MethodGet, Path: "/organizations/:id/members", Handler: auth.ListOrganizationMembers, Permission: OrganizationsMembersPermission},
+		{Method: http.MethodPut, Path: "/organizations/:id/members/:userId", Handler: auth.PutOrganizationMember, Permission: OrganizationsMembersPermission},
+		{Method: http.MethodDelete, Path: "/organizations/:id/members/:userId", Handler: auth.DeleteOrganizationMember, Permission: OrganizationsMembersPermission},
+	}
+
+	registry := NewRouteRegistry("/v1/auth", routes)
+	if err := registry.Validate(KnownPermissions); err != nil {
+		return err
+	}
+	auth.Routes = registry
 
 	users := s.Echo.Group("/v1/auth")
-	users.GET("/health", func(c echo.Context) error {
-		return NewResponse(c, "success", "healthy", "", http.StatusOK)
-	})
+	registry.Register(users, ResolveTenant(s.Cfg, auth.Repo), Authenticate(s.Cfg, auth.Repo))
 
-	auth := AuthHandler{
-		DB:     s.DB,
-		Repo:   repository.New(s.DB),
-		Logger: s.Logger,
-		Cfg:    s.Cfg,
-		Ctx:    s.Ctx,
-	}
-	
-	users.GET("/verify-email", auth.VerifyEmail)
-	users.POST("/register", auth.Register)
-	users.POST("/login", auth.Login)
-	users.POST("/forgot-password", auth.ForgotPassword)
-	users.POST("/refresh-token", auth.RefreshToken) // TODO: implement refresh token
-	users.GET("/logout", auth.Logout)               // TODO: implement logout
-
-	users.Use(JWTMiddleware(s.Cfg.JWTSecret))
-	users.GET("/permissions", auth.GetAllPermissions, Has("permissions:list"))
-	users.POST("/permissions", auth.CreatePermissions, Has("permissions:create"))
-	users.DELETE("/permissions/:id", auth.DeletePermissions, Has("permissions:delete"))
-
-	users.GET("/roles", auth.GetAllRoles, Has("roles:list"))
-	users.GET("/roles/:id", auth.GetOneRole, Has("roles:read"))
-	users.POST("/roles", auth.CreateRoles, Has("roles:create"))
-	users.PUT("/roles/:id", auth.UpdateRoles, Has("roles:update"))
-	users.DELETE("/roles/:id", auth.DeleteRoles, Has("roles:delete"))
-
-	users.GET("/users", auth.GetAllUsers, Has("users:list"))
-	users.GET("/users/:id", auth.GetOneUser, Has("users:read"))
-	users.POST("/users", auth.CreateUsers, Has("users:create"))
-	users.PUT("/users", auth.UpdateUsers, Has("users:update"))
-	users.DELETE("/users", auth.DeleteUsers, Has("users:delete"))
+	return nil
+}
 
+func Health(c echo.Context) error {
+	return NewResponse(c, "success", "healthy", "", http.StatusOK)
 }
 
 func Cors() middleware.CORSConfig {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+	"users/repository"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// NewSessionParams captures where a login is coming from and the
+// organization it signs in to
+func NewSessionParams(c echo.Context, cfg *Config, userID, organizationID int32, device string) repository.CreateSessionParams {
+	userAgent := c.Request().UserAgent()
+	if device == "" {
+		device = userAgent
+	}
+
+	return repository.CreateSessionParams{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Device:         pgtype.Text{String: truncate(device, 255), Valid: device != ""},
+		IpAddress:      pgtype.Text{String: c.RealIP(), Valid: c.RealIP() != ""},
+		UserAgent:      pgtype.Text{String: userAgent, Valid: userAgent != ""},
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(cfg.RefreshTokenTTL), Valid: true},
+	}
+}
+
+// CheckSession makes sure the session a token was issued for has not been
+// revoked and records the activity
+func CheckSession(ctx context.Context, repo *repository.Queries, sessionID int32) error {
+	if sessionID == 0 {
+		return ErrSessionRevoked
+	}
+
+	session, err := repo.GetActiveSession(ctx, sessionID)
+	if err != nil {
+		return ErrSessionRevoked
+	}
+
+	return repo.TouchSession(ctx, session.ID)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func newSessionsDTO(sessions []repository.Session, currentID int32) []SessionDTO {
+	sessionsDTO := make([]SessionDTO, 0)
+	for _, session := range sessions {
+		sessionsDTO = append(sessionsDTO, SessionDTO{
+			ID:         session.ID,
+			Device:     session.Device,
+			IpAddress:  session.IpAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	return sessionsDTO
+}
+
+// sessions handlers
+
+func (h *AuthHandler) ListMySessions(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	sessionID, _ := c.Get("sessionID").(int32)
+
+	sessions, err := h.Repo.ListUserSessions(h.Ctx, int32(userID))
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	return NewResponse(c, "success", newSessionsDTO(sessions, sessionID), "", http.StatusOK)
+}
+
+func (h *AuthHandler) RevokeMySession(c echo.Context) error {
+	userID, _ := c.Get("userID").(int64)
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	revoked, err := h.Repo.RevokeUserSession(h.Ctx, repository.RevokeUserSessionParams{
+		ID:     int32(id),
+		UserID: int32(userID),
+	})
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	if revoked == 0 {
+		return NewResponse(c, "failed", nil, "session not found", http.StatusNotFound)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) ListUserSessions(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.requireTenantUser(c, int32(id)); err != nil {
+		return err
+	}
+
+	sessions, err := h.Repo.ListUserSessions(h.Ctx, int32(id))
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	return NewResponse(c, "success", newSessionsDTO(sessions, 0), "", http.StatusOK)
+}
+
+func (h *AuthHandler) RevokeUserSession(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	sessionID, _ := strconv.Atoi(c.Param("sessionId"))
+	if err := h.requireTenantUser(c, int32(id)); err != nil {
+		return err
+	}
+
+	revoked, err := h.Repo.RevokeUserSession(h.Ctx, repository.RevokeUserSessionParams{
+		ID:     int32(sessionID),
+		UserID: int32(id),
+	})
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	if revoked == 0 {
+		return NewResponse(c, "failed", nil, "session not found", http.StatusNotFound)
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+func (h *AuthHandler) RevokeUserSessions(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := h.requireTenantUser(c, int32(id)); err != nil {
+		return err
+	}
+
+	err := h.Repo.RevokeUserSessions(h.Ctx, int32(id))
+	if err != nil {
+		return DBError(err, "session")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
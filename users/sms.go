@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+const (
+	SmsProviderLog    = "log"
+	SmsProviderMemory = "memory"
+)
+
+// SmsSender delivers text messages. Real providers implement it next to the
+// log and in-memory senders used for local development and tests.
+type SmsSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+func NewSmsSender(cfg *Config, logger *slog.Logger) (SmsSender, error) {
+	switch cfg.SmsProvider {
+	case SmsProviderLog:
+		return &LogSmsSender{Logger: logger}, nil
+	case SmsProviderMemory:
+		return &MemorySmsSender{}, nil
+	default:
+		return nil, fmt.Errorf("unknown sms provider %s", cfg.SmsProvider)
+	}
+}
+
+// LogSmsSender writes messages to the log instead of sending them
+type LogSmsSender struct {
+	Logger *slog.Logger
+}
+
+func (s *LogSmsSender) Send(ctx context.Context, to, message string) error {
+	s.Logger.Info("sms", "to", to, "message", message)
+	return nil
+}
+
+type SmsMessage struct {
+	To      string
+	Message string
+}
+
+// MemorySmsSender keeps every message so they can be inspected
+type MemorySmsSender struct {
+	mu       sync.Mutex
+	Messages []SmsMessage
+}
+
+func (s *MemorySmsSender) Send(ctx context.Context, to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Messages = append(s.Messages, SmsMessage{To: to, Message: message})
+	return nil
+}
+
+// Last returns the latest message sent to a number
+func (s *MemorySmsSender) Last(to string) (SmsMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		if s.Messages[i].To == to {
+			return s.Messages[i], true
+		}
+	}
+	return SmsMessage{}, false
+}
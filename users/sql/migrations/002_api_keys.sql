@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the api key
+    name VARCHAR(100) NOT NULL,                -- Human readable label
+    prefix VARCHAR(16) UNIQUE NOT NULL,        -- Public part of the key, used for lookups
+    key_hash TEXT NOT NULL,                    -- SHA-256 of the full key, the key itself is never stored
+    user_id INTEGER REFERENCES users(id),      -- Owner of a personal key
+    service_account VARCHAR(100),              -- Principal of a service key
+    permissions TEXT[] NOT NULL,               -- Subset of permissions granted to the key
+    expires_at TIMESTAMP DEFAULT NULL,         -- Optional expiry
+    last_used_at TIMESTAMP DEFAULT NULL,       -- Timestamp of the last authenticated request
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    revoked_at TIMESTAMP DEFAULT NULL,         -- Timestamp of revocation
+    CONSTRAINT chk_api_key_principal CHECK ((user_id IS NULL) <> (service_account IS NULL)) -- Exactly one principal
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the session, carried as "sid" in tokens
+    user_id INTEGER NOT NULL REFERENCES users(id), -- Owner of the session
+    device VARCHAR(255),                       -- Device name sent by the client
+    ip_address VARCHAR(45),                    -- IP address the session was created from
+    user_agent TEXT,                           -- User agent the session was created from
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of login
+    last_seen_at TIMESTAMP DEFAULT NOW(),      -- Timestamp of the last authenticated request
+    expires_at TIMESTAMP NOT NULL,             -- Sessions expire together with their refresh token
+    revoked_at TIMESTAMP DEFAULT NULL          -- Timestamp of logout or revocation
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE sessions;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP DEFAULT NOW(); -- Used to force rotation after the max password age
+
+CREATE TABLE password_history (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the entry
+    user_id INTEGER NOT NULL REFERENCES users(id), -- Owner of the password
+    password_hash TEXT NOT NULL,               -- Hash of a previously used password
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of when the password was set
+);
+
+CREATE INDEX idx_password_history_user_id ON password_history (user_id);
+
+-- +goose Down
+DROP TABLE password_history;
+ALTER TABLE users DROP COLUMN password_changed_at;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- Unique values only have to be unique among live rows so soft deleted records can be re-created
+ALTER TABLE users DROP CONSTRAINT users_username_key, DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_username_live_key ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_live_key ON users (email) WHERE deleted_at IS NULL;
+
+ALTER TABLE roles DROP CONSTRAINT roles_role_name_key;
+CREATE UNIQUE INDEX roles_role_name_live_key ON roles (role_name) WHERE deleted_at IS NULL;
+
+ALTER TABLE permissions DROP CONSTRAINT permissions_name_key;
+CREATE UNIQUE INDEX permissions_name_live_key ON permissions (name) WHERE deleted_at IS NULL;
+
+-- Purging a user removes the rows that only make sense while the user exists
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_user_id_fkey,
+    ADD CONSTRAINT api_keys_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE sessions DROP CONSTRAINT sessions_user_id_fkey,
+    ADD CONSTRAINT sessions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE password_history DROP CONSTRAINT password_history_user_id_fkey,
+    ADD CONSTRAINT password_history_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE password_history DROP CONSTRAINT password_history_user_id_fkey,
+    ADD CONSTRAINT password_history_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE sessions DROP CONSTRAINT sessions_user_id_fkey,
+    ADD CONSTRAINT sessions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_user_id_fkey,
+    ADD CONSTRAINT api_keys_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+
+DROP INDEX permissions_name_live_key;
+ALTER TABLE permissions ADD CONSTRAINT permissions_name_key UNIQUE (name);
+
+DROP INDEX roles_role_name_live_key;
+ALTER TABLE roles ADD CONSTRAINT roles_role_name_key UNIQUE (role_name);
+
+DROP INDEX users_email_live_key;
+DROP INDEX users_username_live_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username), ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN anonymized_at TIMESTAMP DEFAULT NULL; -- Timestamp of erasure, PII columns are scrubbed from then on
+
+-- Entries reference users by plain id so they outlive the rows they mention
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the entry
+    actor_id INTEGER,                          -- User who performed the action, NULL for the system or service keys
+    action VARCHAR(100) NOT NULL,              -- What happened, e.g. "users.erase"
+    user_id INTEGER NOT NULL,                  -- User the action was performed on
+    metadata JSONB NOT NULL DEFAULT '{}',      -- Extra details, must never contain PII
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the action
+);
+
+CREATE INDEX idx_audit_log_user_id ON audit_log (user_id);
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor_id);
+
+-- +goose Down
+DROP TABLE audit_log;
+ALTER TABLE users DROP COLUMN anonymized_at;
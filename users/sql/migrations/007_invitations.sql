@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE invitations (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the invitation
+    email VARCHAR(255) NOT NULL,               -- Address the invitation was sent to
+    role BIGINT NOT NULL,                      -- Role the invitee is created with
+    token_hash TEXT UNIQUE NOT NULL,           -- SHA-256 of the emailed token, the token itself is never stored
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL, -- Admin who sent the invitation
+    expires_at TIMESTAMP NOT NULL,             -- The link stops working after this
+    accepted_at TIMESTAMP DEFAULT NULL,        -- Timestamp of acceptance, invitations are single use
+    revoked_at TIMESTAMP DEFAULT NULL,         -- Timestamp of revocation
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    updated_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the last resend
+);
+
+-- At most one open invitation per address
+CREATE UNIQUE INDEX invitations_open_email_key ON invitations (email) WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- +goose Down
+DROP TABLE invitations;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE email_changes (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the request
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User changing their email
+    new_email VARCHAR(255) NOT NULL,           -- Pending address, only becomes the user's email once confirmed
+    confirm_token_hash TEXT UNIQUE NOT NULL,   -- SHA-256 of the token mailed to the new address
+    cancel_token_hash TEXT UNIQUE NOT NULL,    -- SHA-256 of the token mailed to the old address
+    expires_at TIMESTAMP NOT NULL,             -- The confirmation link stops working after this
+    confirmed_at TIMESTAMP DEFAULT NULL,       -- Timestamp of the swap
+    cancelled_at TIMESTAMP DEFAULT NULL,       -- Timestamp of cancellation, by the old address or a newer request
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the request
+);
+
+CREATE INDEX idx_email_changes_user_id ON email_changes (user_id);
+
+-- +goose Down
+DROP TABLE email_changes;
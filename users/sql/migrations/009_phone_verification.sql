@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users ALTER COLUMN phone_number TYPE VARCHAR(16); -- E.164, a "+" followed by up to 15 digits
+ALTER TABLE users ADD COLUMN phone_verified BOOLEAN DEFAULT FALSE; -- Set once the user entered a code sent to phone_number
+ALTER TABLE users ADD COLUMN sms_two_factor BOOLEAN DEFAULT FALSE; -- Require a code sent to the verified phone at login
+
+CREATE TABLE phone_otps (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the code
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User the code was sent to
+    purpose VARCHAR(20) NOT NULL,              -- "verify_phone" or "login"
+    phone_number VARCHAR(16) NOT NULL,         -- Number the code was sent to
+    code_hash TEXT NOT NULL,                   -- SHA-256 of the code
+    attempts INTEGER NOT NULL DEFAULT 0,       -- Wrong guesses so far
+    expires_at TIMESTAMP NOT NULL,             -- Codes are short lived
+    consumed_at TIMESTAMP DEFAULT NULL,        -- Timestamp of use or replacement by a newer code
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of sending
+);
+
+CREATE INDEX idx_phone_otps_user_id ON phone_otps (user_id, purpose);
+
+-- +goose Down
+DROP TABLE phone_otps;
+ALTER TABLE users DROP COLUMN sms_two_factor;
+ALTER TABLE users DROP COLUMN phone_verified;
+ALTER TABLE users ALTER COLUMN phone_number TYPE VARCHAR(15);
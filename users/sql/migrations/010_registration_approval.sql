@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN approval_pending BOOLEAN DEFAULT FALSE; -- Self registered user waiting for an admin, kept inactive until approved
+
+CREATE INDEX idx_users_approval_pending ON users (created_at) WHERE approval_pending AND deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX idx_users_approval_pending;
+ALTER TABLE users DROP COLUMN approval_pending;
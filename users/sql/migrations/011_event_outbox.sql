@@ -0,0 +1,20 @@
+-- +goose Up
+-- Events are written in the transaction of the change they describe and
+-- published from here, so none is lost or sent for a rolled back change
+CREATE TABLE event_outbox (
+    id BIGSERIAL PRIMARY KEY,                  -- Publishing order
+    event_id UUID UNIQUE NOT NULL,             -- Id consumers deduplicate on, deliveries are at least once
+    type VARCHAR(100) NOT NULL,                -- Event type, e.g. "user.registered"
+    version INTEGER NOT NULL,                  -- Schema version of the payload
+    payload JSONB NOT NULL,                    -- The event envelope as published
+    attempts INTEGER NOT NULL DEFAULT 0,       -- Failed publishing attempts so far
+    last_error TEXT DEFAULT NULL,              -- Error of the last failed attempt
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(), -- Failed events wait for their backoff
+    published_at TIMESTAMP DEFAULT NULL,       -- Timestamp of publishing, NULL while pending
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the change
+);
+
+CREATE INDEX idx_event_outbox_pending ON event_outbox (next_attempt_at, id) WHERE published_at IS NULL;
+
+-- +goose Down
+DROP TABLE event_outbox;
@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE webhook_subscriptions (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the subscription
+    url TEXT NOT NULL,                         -- Endpoint the events are posted to
+    events TEXT[] NOT NULL,                    -- Event types delivered, empty for every event
+    secret TEXT NOT NULL,                      -- Key deliveries are signed with, HMAC needs it in the clear
+    description VARCHAR(255) DEFAULT NULL,     -- Human readable label
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,   -- Inactive subscriptions get no new deliveries
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL, -- Admin who created the subscription
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    updated_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the last update
+);
+
+-- One event on its way to one subscription, created with the event so it
+-- is delivered only if the change commits
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,                  -- Unique identifier, sent as X-Webhook-Id
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE, -- Subscription delivered to
+    event_id UUID NOT NULL,                    -- Id of the delivered event
+    event_type VARCHAR(100) NOT NULL,          -- Type of the delivered event
+    payload JSONB NOT NULL,                    -- The event envelope as posted
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, succeeded or failed once attempts ran out
+    attempts INTEGER NOT NULL DEFAULT 0,       -- Attempts made so far
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(), -- Pending deliveries wait for their backoff
+    last_status_code INTEGER DEFAULT NULL,     -- Status the receiver answered the last attempt with
+    last_error TEXT DEFAULT NULL,              -- Why the last attempt failed
+    delivered_at TIMESTAMP DEFAULT NULL,       -- Timestamp of the successful attempt
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of the event
+    CONSTRAINT uq_webhook_delivery UNIQUE (subscription_id, event_id) -- An event is delivered once per subscription
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+
+-- Log of every attempt, kept until the delivery is deleted with its subscription
+CREATE TABLE webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,                  -- Unique identifier for the attempt
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE, -- Delivery attempted
+    status_code INTEGER DEFAULT NULL,          -- Status the receiver answered, NULL when it could not be reached
+    error TEXT DEFAULT NULL,                   -- Why the attempt failed
+    duration_ms INTEGER NOT NULL,              -- Time the attempt took
+    attempted_at TIMESTAMP DEFAULT NOW()       -- Timestamp of the attempt
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery ON webhook_delivery_attempts (delivery_id, id);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,75 @@
+-- +goose Up
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the organization
+    name VARCHAR(100) NOT NULL,                -- Display name
+    slug VARCHAR(63) NOT NULL,                 -- Names the tenant in the X-Tenant header and in subdomains
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,   -- Users of an inactive organization cannot sign in
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    updated_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the last update
+);
+
+CREATE UNIQUE INDEX organizations_slug_key ON organizations (slug);
+
+-- Everything from before organizations belongs to the default one, which also
+-- hosts the platform administrators
+INSERT INTO organizations (id, name, slug) VALUES (1, 'Default', 'default');
+SELECT setval('organizations_id_seq', 1);
+
+ALTER TABLE users ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id); -- Organization the account belongs to, role is the role in it
+ALTER TABLE roles ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id); -- Organization the role is defined in
+ALTER TABLE api_keys ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id); -- Organization the key acts in
+ALTER TABLE invitations ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id); -- Organization the invitee joins
+ALTER TABLE webhook_subscriptions ADD COLUMN organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id); -- Organization whose events are delivered
+
+-- Existing rows are backfilled, new ones have to name their organization
+ALTER TABLE users ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE roles ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE invitations ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE webhook_subscriptions ALTER COLUMN organization_id DROP DEFAULT;
+
+-- Names and addresses only have to be unique within an organization
+DROP INDEX users_username_live_key;
+DROP INDEX users_email_live_key;
+CREATE UNIQUE INDEX users_username_live_key ON users (organization_id, username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_live_key ON users (organization_id, email) WHERE deleted_at IS NULL;
+
+DROP INDEX roles_role_name_live_key;
+CREATE UNIQUE INDEX roles_role_name_live_key ON roles (organization_id, role_name) WHERE deleted_at IS NULL;
+
+DROP INDEX invitations_open_email_key;
+CREATE UNIQUE INDEX invitations_open_email_key ON invitations (organization_id, email) WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+-- Users of one organization can be members of others, with a role there
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE, -- Organization the user is a member of
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Member, belonging to another organization
+    role BIGINT NOT NULL,                      -- Role of the member, one of the organization's roles
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    updated_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of the last role change
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members (user_id);
+
+-- +goose Down
+DROP TABLE organization_members;
+
+DROP INDEX invitations_open_email_key;
+CREATE UNIQUE INDEX invitations_open_email_key ON invitations (email) WHERE accepted_at IS NULL AND revoked_at IS NULL;
+
+DROP INDEX roles_role_name_live_key;
+CREATE UNIQUE INDEX roles_role_name_live_key ON roles (role_name) WHERE deleted_at IS NULL;
+
+DROP INDEX users_email_live_key;
+DROP INDEX users_username_live_key;
+CREATE UNIQUE INDEX users_username_live_key ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_live_key ON users (email) WHERE deleted_at IS NULL;
+
+ALTER TABLE webhook_subscriptions DROP COLUMN organization_id;
+ALTER TABLE invitations DROP COLUMN organization_id;
+ALTER TABLE api_keys DROP COLUMN organization_id;
+ALTER TABLE roles DROP COLUMN organization_id;
+ALTER TABLE users DROP COLUMN organization_id;
+
+DROP TABLE organizations;
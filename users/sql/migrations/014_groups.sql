@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE groups (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the group
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE, -- Organization the group belongs to
+    name VARCHAR(100) NOT NULL,                -- Name of the group, unique within the organization
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of creation
+    updated_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the last rename
+);
+
+CREATE UNIQUE INDEX groups_name_key ON groups (organization_id, name);
+
+-- Roles granted to every member of a group, on top of their own role
+CREATE TABLE group_roles (
+    group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE, -- Group the role is granted to
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE, -- Granted role, one of the organization's
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp of the grant
+    PRIMARY KEY (group_id, role_id)
+);
+
+CREATE TABLE group_members (
+    group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE, -- Group the user is in
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Member, a user or a member of the organization
+    created_at TIMESTAMP DEFAULT NOW(),        -- Timestamp the user was added
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_group_members_user_id ON group_members (user_id);
+
+-- +goose Down
+DROP TABLE group_members;
+DROP TABLE group_roles;
+DROP TABLE groups;
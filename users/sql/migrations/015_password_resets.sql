@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE password_resets (
+    id SERIAL PRIMARY KEY,                     -- Unique identifier for the reset
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- User the password is reset for
+    token_hash TEXT UNIQUE NOT NULL,           -- SHA-256 of the mailed token, the token itself is never stored
+    expires_at TIMESTAMP NOT NULL,             -- The token stops working after this
+    used_at TIMESTAMP DEFAULT NULL,            -- Timestamp of the reset, tokens are single use
+    created_at TIMESTAMP DEFAULT NOW()         -- Timestamp of the request
+);
+
+CREATE INDEX idx_password_resets_user_id ON password_resets (user_id);
+
+-- +goose Down
+DROP TABLE password_resets;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE organizations ADD COLUMN allow_registration BOOLEAN NOT NULL DEFAULT FALSE; -- Whether users may sign up on their own, following REGISTRATION_MODE
+
+-- The default organization kept its self registration
+UPDATE organizations SET allow_registration = TRUE WHERE id = 1;
+
+ALTER TABLE sessions ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE; -- Organization the session signed in to
+
+-- Older sessions are taken for sessions in the user's own organization
+UPDATE sessions SET organization_id = users.organization_id
+FROM users WHERE users.id = sessions.user_id;
+
+ALTER TABLE sessions ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX idx_sessions_organization_id_user_id ON sessions (organization_id, user_id);
+
+-- +goose Down
+DROP INDEX idx_sessions_organization_id_user_id;
+ALTER TABLE sessions DROP COLUMN organization_id;
+ALTER TABLE organizations DROP COLUMN allow_registration;
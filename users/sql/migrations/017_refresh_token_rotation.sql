@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE sessions ADD COLUMN refresh_token_id TEXT; -- jti of the one refresh token of the session that may still be used, rotated on every refresh
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN refresh_token_id;
@@ -0,0 +1,49 @@
+-- name: GetApiKey :one
+SELECT * FROM api_keys
+WHERE id = $1 AND organization_id = $2 AND revoked_at IS NULL
+LIMIT 1;
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+LIMIT 1;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE organization_id = $1 AND revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: ListUserApiKeys :many
+SELECT * FROM api_keys
+WHERE organization_id = $1 AND user_id = $2 AND revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: CreateApiKey :one
+INSERT INTO api_keys (
+  organization_id, name, prefix, key_hash, user_id, service_account, permissions, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeApiKey :exec
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND revoked_at IS NULL;
+
+-- name: ListAllUserApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: RevokeUserApiKeys :exec
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
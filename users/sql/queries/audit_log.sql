@@ -0,0 +1,11 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (
+  actor_id, action, user_id, metadata
+) VALUES (
+  $1, $2, $3, $4
+);
+
+-- name: ListUserAuditEntries :many
+SELECT * FROM audit_log
+WHERE user_id = $1 OR actor_id = $1
+ORDER BY created_at, id;
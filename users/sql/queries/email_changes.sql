@@ -0,0 +1,46 @@
+-- name: GetPendingEmailChangeByConfirmHash :one
+SELECT * FROM email_changes
+WHERE confirm_token_hash = $1
+  AND confirmed_at IS NULL
+  AND cancelled_at IS NULL
+  AND expires_at > NOW()
+LIMIT 1;
+
+-- name: GetPendingUserEmailChange :one
+SELECT * FROM email_changes
+WHERE user_id = $1
+  AND confirmed_at IS NULL
+  AND cancelled_at IS NULL
+  AND expires_at > NOW()
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: CreateEmailChange :one
+INSERT INTO email_changes (
+  user_id, new_email, confirm_token_hash, cancel_token_hash, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ConfirmEmailChange :execrows
+UPDATE email_changes
+SET confirmed_at = NOW()
+WHERE id = $1
+  AND confirmed_at IS NULL
+  AND cancelled_at IS NULL
+  AND expires_at > NOW();
+
+-- name: CancelEmailChange :execrows
+UPDATE email_changes
+SET cancelled_at = NOW()
+WHERE cancel_token_hash = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL;
+
+-- name: CancelUserEmailChanges :exec
+UPDATE email_changes
+SET cancelled_at = NOW()
+WHERE user_id = $1 AND confirmed_at IS NULL AND cancelled_at IS NULL;
+
+-- name: DeleteUserEmailChanges :exec
+DELETE FROM email_changes
+WHERE user_id = $1;
@@ -0,0 +1,27 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO event_outbox (
+  event_id, type, version, payload
+) VALUES (
+  $1, $2, $3, $4
+);
+
+-- name: ClaimOutboxEvents :many
+SELECT * FROM event_outbox
+WHERE published_at IS NULL AND next_attempt_at <= NOW()
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE event_outbox
+SET published_at = NOW(), last_error = NULL
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE event_outbox
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: PurgePublishedOutboxEvents :execrows
+DELETE FROM event_outbox
+WHERE published_at IS NOT NULL AND published_at < NOW() - sqlc.arg(retention)::interval;
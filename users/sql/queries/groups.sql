@@ -0,0 +1,84 @@
+-- name: GetGroup :one
+SELECT * FROM groups
+WHERE id = $1 AND organization_id = $2
+LIMIT 1;
+
+-- name: ListGroups :many
+SELECT * FROM groups
+WHERE organization_id = $1
+ORDER BY name;
+
+-- name: ListUserGroups :many
+SELECT * FROM groups
+WHERE organization_id = sqlc.arg(organization_id)
+  AND id IN (SELECT group_id FROM group_members WHERE group_members.user_id = sqlc.arg(user_id))
+ORDER BY name;
+
+-- name: CreateGroup :one
+INSERT INTO groups (
+  organization_id, name
+) VALUES (
+  $1, $2
+)
+RETURNING *;
+
+-- name: RenameGroup :one
+UPDATE groups
+SET name = $2, updated_at = NOW()
+WHERE id = $1 AND organization_id = $3
+RETURNING *;
+
+-- name: DeleteGroup :execrows
+DELETE FROM groups
+WHERE id = $1 AND organization_id = $2;
+
+-- name: ListGroupMembers :many
+SELECT * FROM group_members
+WHERE group_id = $1
+ORDER BY user_id;
+
+-- name: AddGroupMember :exec
+INSERT INTO group_members (
+  group_id, user_id
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (group_id, user_id) DO NOTHING;
+
+-- name: RemoveGroupMember :execrows
+DELETE FROM group_members
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: RemoveOrganizationGroupMember :exec
+DELETE FROM group_members
+WHERE group_members.user_id = sqlc.arg(user_id)
+  AND group_id IN (SELECT id FROM groups WHERE groups.organization_id = sqlc.arg(organization_id));
+
+-- name: ListGroupRoles :many
+SELECT * FROM roles
+WHERE id IN (SELECT role_id FROM group_roles WHERE group_roles.group_id = sqlc.arg(group_id))
+  AND deleted_at IS NULL
+ORDER BY id;
+
+-- name: GrantGroupRole :exec
+INSERT INTO group_roles (
+  group_id, role_id
+) VALUES (
+  $1, $2
+)
+ON CONFLICT (group_id, role_id) DO NOTHING;
+
+-- name: RevokeGroupRole :execrows
+DELETE FROM group_roles
+WHERE group_id = $1 AND role_id = $2;
+
+-- name: ListUserGroupRoles :many
+SELECT * FROM roles
+WHERE organization_id = sqlc.arg(organization_id)
+  AND deleted_at IS NULL
+  AND id IN (
+    SELECT group_roles.role_id FROM group_roles
+    JOIN group_members ON group_members.group_id = group_roles.group_id
+    WHERE group_members.user_id = sqlc.arg(user_id)
+  )
+ORDER BY id;
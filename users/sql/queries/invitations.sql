@@ -0,0 +1,68 @@
+-- name: GetInvitation :one
+SELECT * FROM invitations
+WHERE id = $1 AND organization_id = $2
+LIMIT 1;
+
+-- name: GetPendingInvitationByTokenHash :one
+SELECT * FROM invitations
+WHERE token_hash = $1
+  AND accepted_at IS NULL
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+LIMIT 1;
+
+-- name: ListInvitations :many
+SELECT * FROM invitations
+WHERE organization_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListPendingInvitations :many
+SELECT * FROM invitations
+WHERE organization_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: CreateInvitation :one
+INSERT INTO invitations (
+  organization_id, email, role, token_hash, invited_by, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: RenewInvitation :one
+UPDATE invitations
+SET token_hash = $2,
+    expires_at = $3,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $4 AND accepted_at IS NULL AND revoked_at IS NULL
+RETURNING *;
+
+-- name: AcceptInvitation :execrows
+UPDATE invitations
+SET accepted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+  AND accepted_at IS NULL
+  AND revoked_at IS NULL
+  AND expires_at > NOW();
+
+-- name: RevokeInvitation :execrows
+UPDATE invitations
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeEmailInvitations :exec
+UPDATE invitations
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE organization_id = $1 AND email = $2 AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- name: ListEmailInvitations :many
+SELECT * FROM invitations
+WHERE organization_id = $1 AND lower(email) = lower($2)
+ORDER BY created_at DESC;
+
+-- name: DeleteEmailInvitations :exec
+DELETE FROM invitations
+WHERE organization_id = $1 AND lower(email) = lower($2);
@@ -0,0 +1,63 @@
+-- name: GetOrganization :one
+SELECT * FROM organizations
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetOrganizationBySlug :one
+SELECT * FROM organizations
+WHERE slug = $1
+LIMIT 1;
+
+-- name: ListOrganizations :many
+SELECT * FROM organizations
+ORDER BY slug;
+
+-- name: ListUserOrganizations :many
+SELECT * FROM organizations
+WHERE id = (SELECT organization_id FROM users WHERE users.id = sqlc.arg(user_id))
+   OR id IN (SELECT organization_id FROM organization_members WHERE organization_members.user_id = sqlc.arg(user_id))
+ORDER BY slug;
+
+-- name: CreateOrganization :one
+INSERT INTO organizations (
+  name, slug, is_active, allow_registration
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: UpdateOrganization :one
+UPDATE organizations
+SET name = $2, slug = $3, is_active = $4, allow_registration = $5, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members
+WHERE organization_id = $1 AND user_id = $2
+LIMIT 1;
+
+-- name: ListOrganizationMembers :many
+SELECT * FROM organization_members
+WHERE organization_id = $1
+ORDER BY user_id;
+
+-- name: UpsertOrganizationMember :one
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT $1::int, id, $3::bigint FROM users
+WHERE users.id = $2 AND users.organization_id <> $1::int AND users.deleted_at IS NULL
+ON CONFLICT (organization_id, user_id) DO UPDATE
+SET role = EXCLUDED.role,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: UpdateOrganizationMemberRole :one
+UPDATE organization_members
+SET role = $3,
+    updated_at = NOW()
+WHERE organization_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = $1 AND user_id = $2;
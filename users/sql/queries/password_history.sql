@@ -0,0 +1,31 @@
+-- name: ListPasswordHistory :many
+SELECT * FROM password_history
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: CreatePasswordHistory :exec
+INSERT INTO password_history (
+  user_id, password_hash
+) VALUES (
+  $1, $2
+);
+
+-- name: PrunePasswordHistory :exec
+DELETE FROM password_history
+WHERE user_id = $1
+  AND id NOT IN (
+    SELECT id FROM password_history
+    WHERE user_id = $1
+    ORDER BY created_at DESC, id DESC
+    LIMIT $2
+  );
+
+-- name: ListAllPasswordHistory :many
+SELECT * FROM password_history
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: DeleteUserPasswordHistory :exec
+DELETE FROM password_history
+WHERE user_id = $1;
@@ -0,0 +1,23 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (
+  user_id, token_hash, expires_at
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetPendingPasswordResetByTokenHash :one
+SELECT * FROM password_resets
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > NOW()
+LIMIT 1;
+
+-- name: UseUserPasswordResets :execrows
+UPDATE password_resets
+SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW();
+
+-- name: DeleteUserPasswordResets :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
@@ -0,0 +1,41 @@
+-- name: GetActivePhoneOtp :one
+SELECT * FROM phone_otps
+WHERE user_id = sqlc.arg(user_id)
+  AND purpose = sqlc.arg(purpose)
+  AND consumed_at IS NULL
+  AND expires_at > NOW()
+  AND attempts < sqlc.arg(max_attempts)::int
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: CountRecentPhoneOtps :one
+SELECT COUNT(*) FILTER (WHERE created_at > NOW() - sqlc.arg(period)::interval)::int AS sent,
+       COUNT(*) FILTER (WHERE created_at > NOW() - sqlc.arg(cooldown)::interval)::int AS cooling
+FROM phone_otps
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: CreatePhoneOtp :exec
+INSERT INTO phone_otps (
+  user_id, purpose, phone_number, code_hash, expires_at
+) VALUES (
+  $1, $2, $3, $4, $5
+);
+
+-- name: IncrementPhoneOtpAttempts :exec
+UPDATE phone_otps
+SET attempts = attempts + 1
+WHERE id = $1;
+
+-- name: ConsumePhoneOtp :execrows
+UPDATE phone_otps
+SET consumed_at = NOW()
+WHERE id = $1 AND consumed_at IS NULL;
+
+-- name: InvalidatePhoneOtps :exec
+UPDATE phone_otps
+SET consumed_at = NOW()
+WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL;
+
+-- name: DeleteUserPhoneOtps :exec
+DELETE FROM phone_otps
+WHERE user_id = $1;
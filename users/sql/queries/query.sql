@@ -1,83 +1,249 @@
 -- name: GetUser :one
 SELECT * FROM users
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: GetTenantUser :one
+SELECT * FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+  AND (organization_id = $2 OR EXISTS (
+    SELECT 1 FROM organization_members
+    WHERE organization_members.user_id = users.id AND organization_members.organization_id = $2
+  ))
 LIMIT 1;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users 
-WHERE email = $1 
-  AND is_verified = true 
-  AND deleted_at IS NULL 
-LIMIT 1; 
+SELECT * FROM users
+WHERE email = $2
+  AND is_verified = true
+  AND deleted_at IS NULL
+  AND (organization_id = $1 OR EXISTS (
+    SELECT 1 FROM organization_members
+    WHERE organization_members.user_id = users.id AND organization_members.organization_id = $1
+  ))
+ORDER BY organization_id = $1 DESC, id
+LIMIT 1;
+
+-- name: GetLiveUserByEmail :one
+SELECT * FROM users
+WHERE organization_id = $1 AND email = $2 AND deleted_at IS NULL
+LIMIT 1;
 
 -- name: ListUsers :many
 SELECT * FROM users
-WHERE deleted_at IS NULL
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY username;
 
+-- name: ListDeletedUsers :many
+SELECT * FROM users
+WHERE organization_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
 -- name: CreateUser :one
 INSERT INTO users (
-  username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
+  organization_id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
 RETURNING *;
 
+-- name: CreateUserIfNotExists :exec
+INSERT INTO users (
+  organization_id, username, email, password, first_name, last_name, phone_number, is_active, is_verified, role
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT DO NOTHING;
+
 -- name: UpdateUser :exec
 UPDATE users
 SET username = $2,
-    email = $3,
-    password = $4,
-    first_name = $5,
-    last_name = $6,
-    phone_number = $7,
-    is_active = $8,
-    is_verified = $9,
-    role = $10,
+    first_name = $3,
+    last_name = $4,
+    phone_number = $5,
+    phone_verified = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE phone_verified END,
+    sms_two_factor = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE sms_two_factor END,
+    is_active = $6,
+    is_verified = $7,
+    role = $8,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $9 AND deleted_at IS NULL
 RETURNING *;
 
+-- name: UpdateUserEmail :exec
+UPDATE users
+SET email = $2,
+    is_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
+
+-- name: UpdateUserProfile :exec
+UPDATE users
+SET username = $2,
+    first_name = $3,
+    last_name = $4,
+    phone_number = $5,
+    phone_verified = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE phone_verified END,
+    sms_two_factor = CASE WHEN phone_number IS DISTINCT FROM $5 THEN FALSE ELSE sms_two_factor END,
+    is_active = $6,
+    role = $7,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $8 AND deleted_at IS NULL;
+
 -- name: SoftDeleteUser :exec
 UPDATE users
 SET deleted_at = NOW()
-WHERE id = $1;
+WHERE id = $1 AND organization_id = $2;
 
 -- name: HardDeleteUser :exec
 DELETE FROM users
-WHERE id = $1;
+WHERE id = $1 AND organization_id = $2;
+
+-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NOT NULL;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < NOW() - sqlc.arg(retention)::interval;
+
+-- name: GetUserOrganizationID :one
+SELECT organization_id FROM users
+WHERE id = $1 AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: GetUserIncludingDeleted :one
+SELECT * FROM users
+WHERE id = $1 AND organization_id = $2
+LIMIT 1;
+
+-- name: AnonymizeUser :execrows
+UPDATE users
+SET username = 'erased-' || id,
+    email = 'erased-' || id || '@erased.invalid',
+    password = '!',
+    first_name = NULL,
+    last_name = NULL,
+    phone_number = NULL,
+    phone_verified = FALSE,
+    sms_two_factor = FALSE,
+    is_active = FALSE,
+    is_verified = FALSE,
+    anonymized_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND anonymized_at IS NULL;
+
+-- name: VerifyUserPhone :execrows
+UPDATE users
+SET phone_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND phone_number = $2 AND organization_id = $3 AND deleted_at IS NULL;
+
+-- name: SetUserSmsTwoFactor :exec
+UPDATE users
+SET sms_two_factor = $2,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
+
+-- name: ListPendingApprovalUsers :many
+SELECT * FROM users
+WHERE organization_id = $1 AND approval_pending AND deleted_at IS NULL
+ORDER BY created_at;
+
+-- name: RequireUserApproval :exec
+UPDATE users
+SET approval_pending = TRUE,
+    is_active = FALSE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL;
+
+-- name: ApproveUser :execrows
+UPDATE users
+SET approval_pending = FALSE,
+    is_active = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND approval_pending AND deleted_at IS NULL;
+
+-- name: RejectUser :execrows
+UPDATE users
+SET approval_pending = FALSE,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND approval_pending AND deleted_at IS NULL;
 
 -- name: ActivateUser :exec
 UPDATE users
 SET is_active = TRUE,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL;
 
 -- name: DeactivateUser :exec
 UPDATE users
 SET is_active = FALSE,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = $2,
+    password_changed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
+
+-- name: UpdateUserPasswordHash :exec
+UPDATE users
+SET password = $2
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
+
+-- name: VerifyUser :exec
+UPDATE users
+SET is_verified = TRUE,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL;
 
 
 ------------------------ROLES--------------------------------------
 
 -- name: GetRole :one
 SELECT * FROM roles
-WHERE id = $1 AND deleted_at IS NULL
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NULL
+LIMIT 1;
+
+-- name: GetRoleByName :one
+SELECT * FROM roles
+WHERE organization_id = $1 AND role_name = $2 AND deleted_at IS NULL
 LIMIT 1;
 
 -- name: ListRoles :many
 SELECT * FROM roles
-WHERE deleted_at IS NULL
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY role_name;
 
+-- name: ListDeletedRoles :many
+SELECT * FROM roles
+WHERE organization_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
 -- name: CreateRole :one
 INSERT INTO roles (
-  role_name, permissions
+  organization_id, role_name, permissions
+) VALUES (
+  $1, $2, $3
+)
+RETURNING *;
+
+-- name: UpsertRole :one
+INSERT INTO roles (
+  organization_id, role_name, permissions
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 )
+ON CONFLICT (organization_id, role_name) WHERE deleted_at IS NULL DO UPDATE
+SET permissions = EXCLUDED.permissions,
+    updated_at = NOW()
 RETURNING *;
 
 -- name: UpdateRole :exec
@@ -85,28 +251,40 @@ UPDATE roles
 SET role_name = $2,
     permissions = $3,
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
+WHERE id = $1 AND organization_id = $4 AND deleted_at IS NULL;
 
 -- name: SoftDeleteRole :exec
 UPDATE roles
 SET deleted_at = NOW()
-WHERE id = $1;
+WHERE id = $1 AND organization_id = $2;
 
 -- name: HardDeleteRole :exec
 DELETE FROM roles
-WHERE id = $1;
+WHERE id = $1 AND organization_id = $2;
+
+-- name: RestoreRole :execrows
+UPDATE roles
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND organization_id = $2 AND deleted_at IS NOT NULL;
+
+-- name: PurgeDeletedRoles :execrows
+DELETE FROM roles
+WHERE deleted_at < NOW() - sqlc.arg(retention)::interval
+  AND NOT EXISTS (SELECT 1 FROM users WHERE users.role = roles.id)
+  AND NOT EXISTS (SELECT 1 FROM organization_members WHERE organization_members.role = roles.id);
 
 -- name: AddPermissionToRole :exec
 UPDATE roles
 SET permissions = array_append(permissions, $2),
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
 
 -- name: RemovePermissionFromRole :exec
 UPDATE roles
 SET permissions = array_remove(permissions, $2),
     updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
+WHERE id = $1 AND organization_id = $3 AND deleted_at IS NULL;
 
 ------------------------Permissions------------------------
 
@@ -120,6 +298,11 @@ SELECT * FROM permissions
 WHERE deleted_at IS NULL
 ORDER BY name;
 
+-- name: ListDeletedPermissions :many
+SELECT * FROM permissions
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
 -- name: CreatePermission :one
 INSERT INTO permissions (
   name
@@ -128,6 +311,15 @@ INSERT INTO permissions (
 )
 RETURNING *;
 
+-- name: UpsertPermission :exec
+INSERT INTO permissions (
+  name
+) VALUES (
+  $1
+)
+ON CONFLICT (name) WHERE deleted_at IS NULL DO UPDATE
+SET updated_at = NOW();
+
 -- name: UpdatePermission :exec
 UPDATE permissions
 SET name = $2,
@@ -141,4 +333,14 @@ WHERE id = $1;
 
 -- name: HardDeletePermission :exec
 DELETE FROM permissions
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: RestorePermission :execrows
+UPDATE permissions
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: PurgeDeletedPermissions :execrows
+DELETE FROM permissions
+WHERE deleted_at < NOW() - sqlc.arg(retention)::interval;
\ No newline at end of file
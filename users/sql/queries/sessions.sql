@@ -0,0 +1,64 @@
+-- name: GetActiveSession :one
+SELECT * FROM sessions
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+LIMIT 1;
+
+-- name: ListUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+ORDER BY last_seen_at DESC;
+
+-- name: CreateSession :one
+INSERT INTO sessions (
+  user_id, organization_id, device, ip_address, user_agent, expires_at, refresh_token_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = NOW()
+WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute';
+
+-- name: RotateSessionRefreshToken :execrows
+UPDATE sessions
+SET refresh_token_id = sqlc.arg(new_refresh_token_id),
+    last_seen_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND (refresh_token_id = sqlc.arg(refresh_token_id) OR refresh_token_id IS NULL)
+  AND revoked_at IS NULL
+  AND expires_at > NOW();
+
+-- name: RevokeSession :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserSession :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserOrganizationSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1 AND organization_id = $2 AND revoked_at IS NULL;
+
+-- name: ListAllUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1;
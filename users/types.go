@@ -1,15 +1,23 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"users/repository"
+
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
 )
 
 type Response struct {
-	Message string `json:"message"`
-	Data    any    `json:"data"`
-	Err     string `json:"error,omitempty"`
-	Code    int    `json:"code"`
+	Message   string       `json:"message"`
+	Data      any          `json:"data"`
+	Err       string       `json:"error,omitempty"`
+	ErrorCode string       `json:"error_code,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Code      int          `json:"code"`
 }
 
 func NewResponse(c echo.Context, message string, data any, err string, code int) error {
@@ -20,6 +28,7 @@ func NewResponse(c echo.Context, message string, data any, err string, code int)
 
 	if err != "" {
 		res.Err = err
+		res.ErrorCode = codeForStatus(code)
 	}
 
 	if data != nil {
@@ -29,6 +38,29 @@ func NewResponse(c echo.Context, message string, data any, err string, code int)
 	return c.JSON(res.Code, res)
 }
 
+// NewValidationResponse answers a failed c.Validate, field errors are listed
+// in the errors array of the envelope
+func NewValidationResponse(c echo.Context, err error) error {
+	var validationErrors ValidationErrors
+	var policyErr *PasswordPolicyError
+	switch {
+	case errors.As(err, &validationErrors):
+	case errors.As(err, &policyErr):
+		validationErrors = policyErr.Fields()
+	default:
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusUnprocessableEntity)
+	}
+
+	res := &Response{
+		Message:   "failed",
+		Err:       "validation failed",
+		ErrorCode: CodeValidationFailed,
+		Errors:    validationErrors,
+		Code:      http.StatusUnprocessableEntity,
+	}
+	return c.JSON(res.Code, res)
+}
+
 func (r Response) Error() string {
 	if r.Err != "" {
 		return r.Err
@@ -37,29 +69,423 @@ func (r Response) Error() string {
 }
 
 type PermissionsDTO struct {
-	Permissions []string `json:"permissions" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required,min=1,dive,required,max=100"`
+}
+
+// RoleDTO is the body of CreateRoles and UpdateRoles
+type RoleDTO struct {
+	RoleName    string   `json:"role_name" validate:"required,max=100"`
+	Permissions []string `json:"permissions" validate:"dive,required,max=100"`
+}
+
+// CreateUserDTO is the body of CreateUsers, the generated repository params
+// carry no validation rules
+type CreateUserDTO struct {
+	Username    string `json:"username" validate:"required,min=3,max=50"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	Password    string `json:"password" validate:"required"`
+	FirstName   string `json:"first_name" validate:"max=50"`
+	LastName    string `json:"last_name" validate:"max=50"`
+	PhoneNumber string `json:"phone_number" validate:"max=20"`
+	IsActive    bool   `json:"is_active"`
+	IsVerified  bool   `json:"is_verified"`
+	Role        int64  `json:"role" validate:"required,gt=0"`
+}
+
+// UpdateUserDTO is the body of UpdateUsers. The email has its own flow, the
+// password is only changed when one is given and the account state when
+// is_active or is_verified are sent.
+type UpdateUserDTO struct {
+	Username    string `json:"username" validate:"required,min=3,max=50"`
+	Password    string `json:"password"`
+	FirstName   string `json:"first_name" validate:"max=50"`
+	LastName    string `json:"last_name" validate:"max=50"`
+	PhoneNumber string `json:"phone_number" validate:"max=20"`
+	IsActive    *bool  `json:"is_active"`
+	IsVerified  *bool  `json:"is_verified"`
+	Role        int64  `json:"role" validate:"required,gt=0"`
+}
+
+// RegisterDTO is the body of Register, the role and account state are up to
+// the server and cannot be chosen by the caller
+type RegisterDTO struct {
+	Username    string `json:"username" validate:"required,min=3,max=50"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	Password    string `json:"password" validate:"required"`
+	FirstName   string `json:"first_name" validate:"max=50"`
+	LastName    string `json:"last_name" validate:"max=50"`
+	PhoneNumber string `json:"phone_number" validate:"max=20"`
 }
 
 type UserGetDTO struct {
-	ID          int32            `json:"id"`
-	Username    string           `json:"username"`
-	Email       string           `json:"email"`
-	FirstName   pgtype.Text      `json:"first_name"`
-	LastName    pgtype.Text      `json:"last_name"`
-	PhoneNumber pgtype.Text      `json:"phone_number"`
-	IsActive    pgtype.Bool      `json:"is_active"`
-	IsVerified  pgtype.Bool      `json:"is_verified"`
-	Role        int64            `json:"role"`
-	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
-	DeletedAt   pgtype.Timestamp `json:"deleted_at"`
+	ID              int32            `json:"id"`
+	OrganizationID  int32            `json:"organization_id"`
+	Username        string           `json:"username"`
+	Email           string           `json:"email"`
+	FirstName       pgtype.Text      `json:"first_name"`
+	LastName        pgtype.Text      `json:"last_name"`
+	PhoneNumber     pgtype.Text      `json:"phone_number"`
+	PhoneVerified   pgtype.Bool      `json:"phone_verified"`
+	SmsTwoFactor    pgtype.Bool      `json:"sms_two_factor"`
+	IsActive        pgtype.Bool      `json:"is_active"`
+	IsVerified      pgtype.Bool      `json:"is_verified"`
+	ApprovalPending pgtype.Bool      `json:"approval_pending"`
+	Role            int64            `json:"role"`
+	CreatedAt       pgtype.Timestamp `json:"created_at"`
+	UpdatedAt       pgtype.Timestamp `json:"updated_at"`
+	DeletedAt       pgtype.Timestamp `json:"deleted_at"`
 }
 
 type LoginDTO struct {
-	Email    string `json:"email" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
+	Device   string `json:"device" validate:"max=255"`
+}
+
+// LoginResponseDTO is returned by Login and VerifyLogin
+type LoginResponseDTO struct {
+	Token        string     `json:"token"`
+	RefreshToken string     `json:"refresh_token"`
+	User         UserGetDTO `json:"user"`
+}
+
+// TwoFactorChallengeDTO answers a login that needs an sms code, see VerifyLoginDTO
+type TwoFactorChallengeDTO struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Challenge         string `json:"challenge"`
+}
+
+// AccessTokenDTO answers a refresh, the refresh token replaces the one sent
+type AccessTokenDTO struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthorizeCheckDTO names what to check, a permission or a route of this
+// service by method and path. One of permission and path is required.
+type AuthorizeCheckDTO struct {
+	Permission string `json:"permission" validate:"max=100"`
+	Method     string `json:"method" validate:"required_with=Path,max=10"`
+	Path       string `json:"path" validate:"max=2048"`
+}
+
+// AuthorizeDTO asks about a token, or about a subject by user id when the
+// caller may introspect. Without either the request's credentials are used.
+type AuthorizeDTO struct {
+	Token      string `json:"token"`
+	Subject    int64  `json:"subject" validate:"gte=0"`
+	Permission string `json:"permission" validate:"max=100"`
+	Method     string `json:"method" validate:"required_with=Path,max=10"`
+	Path       string `json:"path" validate:"max=2048"`
+}
+
+type AuthorizeBatchDTO struct {
+	Token   string              `json:"token"`
+	Subject int64               `json:"subject" validate:"gte=0"`
+	Checks  []AuthorizeCheckDTO `json:"checks" validate:"required,min=1,max=100,dive"`
+}
+
+type AuthorizeDecisionDTO struct {
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+	Permission string `json:"permission,omitempty"`
+	Method     string `json:"method,omitempty"`
+	Path       string `json:"path,omitempty"`
+	UserID     int64  `json:"user_id,omitempty"`
+}
+
+// AuthorizeBatchDecisionDTO is allowed when every check is
+type AuthorizeBatchDecisionDTO struct {
+	UserID    int64                  `json:"user_id,omitempty"`
+	Allowed   bool                   `json:"allowed"`
+	Decisions []AuthorizeDecisionDTO `json:"decisions"`
+}
+
+type EventSchemaDTO struct {
+	Type    string         `json:"type"`
+	Version int            `json:"version"`
+	Schema  map[string]any `json:"schema"`
 }
 
 type ForgotPasswordDTO struct {
-	Email string `json:"email" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type CreateApiKeyDTO struct {
+	Name           string     `json:"name" validate:"required,max=100"`
+	Permissions    []string   `json:"permissions" validate:"required,min=1,unique"`
+	ServiceAccount string     `json:"service_account" validate:"max=100"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+type ApiKeyDTO struct {
+	ID             int32            `json:"id"`
+	Name           string           `json:"name"`
+	Prefix         string           `json:"prefix"`
+	UserID         pgtype.Int4      `json:"user_id"`
+	ServiceAccount pgtype.Text      `json:"service_account"`
+	Permissions    []string         `json:"permissions"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	LastUsedAt     pgtype.Timestamp `json:"last_used_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+// CreatedApiKeyDTO carries the plain key, it is only shown once
+type CreatedApiKeyDTO struct {
+	Key    string    `json:"key"`
+	ApiKey ApiKeyDTO `json:"api_key"`
+}
+
+type SessionDTO struct {
+	ID         int32            `json:"id"`
+	Device     pgtype.Text      `json:"device"`
+	IpAddress  pgtype.Text      `json:"ip_address"`
+	UserAgent  pgtype.Text      `json:"user_agent"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	LastSeenAt pgtype.Timestamp `json:"last_seen_at"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	Current    bool             `json:"current"`
+}
+
+type AuditEntryDTO struct {
+	ID        int32            `json:"id"`
+	ActorID   pgtype.Int4      `json:"actor_id"`
+	Action    string           `json:"action"`
+	UserID    int32            `json:"user_id"`
+	Metadata  json.RawMessage  `json:"metadata"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type RoleExportDTO struct {
+	ID          int32    `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserExportDTO struct {
+	ExportedAt        time.Time          `json:"exported_at"`
+	User              UserGetDTO         `json:"user"`
+	PasswordChangedAt pgtype.Timestamp   `json:"password_changed_at"`
+	AnonymizedAt      pgtype.Timestamp   `json:"anonymized_at"`
+	Role              *RoleExportDTO     `json:"role"`
+	Sessions          []SessionDTO       `json:"sessions"`
+	ApiKeys           []ApiKeyDTO        `json:"api_keys"`
+	PasswordHistory   []pgtype.Timestamp `json:"password_history"`
+	Invitations       []InvitationDTO    `json:"invitations"`
+	AuditEntries      []AuditEntryDTO    `json:"audit_entries"`
+}
+
+// ImportUserRow is one user of an import, csv columns use the json names
+type ImportUserRow struct {
+	Username    string  `json:"username" validate:"required,min=3,max=50"`
+	Email       string  `json:"email" validate:"required,email,max=255"`
+	Password    string  `json:"password"`
+	FirstName   *string `json:"first_name" validate:"omitempty,max=50"`
+	LastName    *string `json:"last_name" validate:"omitempty,max=50"`
+	PhoneNumber *string `json:"phone_number" validate:"omitempty,max=20"`
+	Role        string  `json:"role" validate:"required"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+type ExportUserRow struct {
+	ID          int32  `json:"id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	PhoneNumber string `json:"phone_number"`
+	Role        string `json:"role"`
+	IsActive    bool   `json:"is_active"`
+	IsVerified  bool   `json:"is_verified"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type ImportRowError struct {
+	Line  int    `json:"line,omitempty"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Invited int              `json:"invited"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type CreateInvitationDTO struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  int64  `json:"role" validate:"required,gt=0"`
+}
+
+type AcceptInvitationDTO struct {
+	Token       string `json:"token" validate:"required"`
+	Username    string `json:"username" validate:"required,min=3,max=50"`
+	Password    string `json:"password" validate:"required"`
+	FirstName   string `json:"first_name" validate:"max=50"`
+	LastName    string `json:"last_name" validate:"max=50"`
+	PhoneNumber string `json:"phone_number" validate:"max=20"`
+}
+
+type InvitationDTO struct {
+	ID         int32            `json:"id"`
+	Email      string           `json:"email"`
+	Role       int64            `json:"role"`
+	InvitedBy  pgtype.Int4      `json:"invited_by"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	AcceptedAt pgtype.Timestamp `json:"accepted_at"`
+	RevokedAt  pgtype.Timestamp `json:"revoked_at"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	UpdatedAt  pgtype.Timestamp `json:"updated_at"`
+}
+
+type ChangeEmailDTO struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required"`
+}
+
+type AdminChangeEmailDTO struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type EmailChangeDTO struct {
+	NewEmail  string           `json:"new_email"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type VerifyPhoneDTO struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type SmsTwoFactorDTO struct {
+	Enabled  bool   `json:"enabled"`
+	Password string `json:"password" validate:"required"`
+}
+
+type VerifyLoginDTO struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required,len=6,numeric"`
+	Device    string `json:"device" validate:"max=255"`
+}
+
+type WebhookDTO struct {
+	Url         string   `json:"url" validate:"required,url,max=2048"`
+	Events      []string `json:"events" validate:"unique"`
+	Description string   `json:"description" validate:"max=255"`
+	IsActive    *bool    `json:"is_active"`
+}
+
+type WebhookSubscriptionDTO struct {
+	ID          int32            `json:"id"`
+	Url         string           `json:"url"`
+	Events      []string         `json:"events"`
+	Description pgtype.Text      `json:"description"`
+	IsActive    bool             `json:"is_active"`
+	CreatedBy   pgtype.Int4      `json:"created_by"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
+}
+
+// CreatedWebhookDTO carries the signing secret, it is only shown when the
+// subscription is created or its secret rotated
+type CreatedWebhookDTO struct {
+	Secret  string                 `json:"secret"`
+	Webhook WebhookSubscriptionDTO `json:"webhook"`
+}
+
+type WebhookDeliveryDTO struct {
+	ID             int64            `json:"id"`
+	SubscriptionID int32            `json:"subscription_id"`
+	EventID        string           `json:"event_id"`
+	EventType      string           `json:"event_type"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamp `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4      `json:"last_status_code"`
+	LastError      pgtype.Text      `json:"last_error"`
+	DeliveredAt    pgtype.Timestamp `json:"delivered_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type WebhookDeliveryAttemptDTO struct {
+	StatusCode  pgtype.Int4      `json:"status_code"`
+	Error       pgtype.Text      `json:"error"`
+	DurationMs  int32            `json:"duration_ms"`
+	AttemptedAt pgtype.Timestamp `json:"attempted_at"`
+}
+
+// WebhookDeliveryLogDTO is a delivery with what was posted and every attempt
+type WebhookDeliveryLogDTO struct {
+	Delivery WebhookDeliveryDTO          `json:"delivery"`
+	Payload  json.RawMessage             `json:"payload"`
+	Attempts []WebhookDeliveryAttemptDTO `json:"attempts"`
+}
+
+// OrganizationDTO is the body of CreateOrganization and UpdateOrganization,
+// AdminEmail is only used on create. Self registration is closed unless
+// AllowRegistration opens it.
+type OrganizationDTO struct {
+	Name              string `json:"name" validate:"required,max=100"`
+	Slug              string `json:"slug" validate:"required,max=63"`
+	IsActive          *bool  `json:"is_active"`
+	AllowRegistration *bool  `json:"allow_registration"`
+	AdminEmail        string `json:"admin_email" validate:"omitempty,email,max=255"`
+}
+
+type OrganizationGetDTO struct {
+	ID                int32            `json:"id"`
+	Name              string           `json:"name"`
+	Slug              string           `json:"slug"`
+	IsActive          bool             `json:"is_active"`
+	AllowRegistration bool             `json:"allow_registration"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	UpdatedAt         pgtype.Timestamp `json:"updated_at"`
+}
+
+type OrganizationMemberDTO struct {
+	Role int64 `json:"role" validate:"required,gt=0"`
+}
+
+type JoinOrganizationDTO struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type GroupDTO struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// GroupGetDTO lists the granted roles only when a single group is read
+type GroupGetDTO struct {
+	ID        int32             `json:"id"`
+	Name      string            `json:"name"`
+	Roles     []repository.Role `json:"roles,omitempty"`
+	CreatedAt pgtype.Timestamp  `json:"created_at"`
+	UpdatedAt pgtype.Timestamp  `json:"updated_at"`
+}
+
+type GroupMemberGetDTO struct {
+	GroupID   int32            `json:"group_id"`
+	UserID    int32            `json:"user_id"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type OrganizationMemberGetDTO struct {
+	OrganizationID int32            `json:"organization_id"`
+	UserID         int32            `json:"user_id"`
+	Role           int64            `json:"role"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
 }
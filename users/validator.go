@@ -2,41 +2,165 @@ package main
 
 import (
 	"fmt"
-	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator"
-	"github.com/labstack/echo/v4"
 )
 
 type CustomValidator struct {
 	Validator *validator.Validate
 }
 
-func (cv *CustomValidator) Validate(i interface{}) error {
-	if err := cv.Validator.Struct(i); err != nil {
-		errors := make(map[string]string)
-
-		fieldErrors, Ok := err.(validator.ValidationErrors)
-		if Ok {
-			for _, err := range fieldErrors {
-				errors[err.Field()] = getErrorMessage(err)
-			}
+// NewValidator reports fields by their json name so errors match the request body
+func NewValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
 		}
+		return name
+	})
+	return &CustomValidator{Validator: v}
+}
+
+// FieldError describes one failed rule, params holds the rule arguments,
+// e.g. {"min": "3"} or {"oneof": ["a", "b"]}
+type FieldError struct {
+	Field   string         `json:"field"`
+	Rule    string         `json:"rule"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// ValidationErrors is returned by Validate and rendered as the errors array
+// of the response envelope
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0)
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Field+" "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (cv *CustomValidator) Validate(i interface{}) error {
+	err := cv.Validator.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	errors := make(ValidationErrors, 0)
+	for _, fieldError := range fieldErrors {
+		errors = append(errors, FieldError{
+			Field:   fieldName(fieldError),
+			Rule:    fieldError.Tag(),
+			Message: getErrorMessage(fieldError),
+			Params:  getErrorParams(fieldError),
+		})
+	}
+	return errors
+}
+
+// fieldName drops the struct name from the namespace, nested fields keep
+// their path, e.g. "permissions[0]"
+func fieldName(err validator.FieldError) string {
+	_, name, found := strings.Cut(err.Namespace(), ".")
+	if !found {
+		return err.Field()
+	}
+	return name
+}
 
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, errors)
+func getErrorParams(err validator.FieldError) map[string]any {
+	if err.Param() == "" {
+		return nil
+	}
+	if err.Tag() == "oneof" {
+		return map[string]any{err.Tag(): strings.Fields(err.Param())}
 	}
-	return nil
+	return map[string]any{err.Tag(): err.Param()}
 }
 
 // getErrorMessage returns a readable message based on the validation error
 func getErrorMessage(err validator.FieldError) string {
+	// size rules count characters for strings and items for slices and maps
+	unit := ""
+	switch err.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
 	switch err.Tag() {
 	case "required":
 		return "is required"
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", err.Param())
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", err.Param())
 	case "email":
 		return "must be a valid email"
 	case "min":
-		return fmt.Sprintf("must be at least %s characters long", err.Param())
+		if unit == "" {
+			return fmt.Sprintf("must be at least %s", err.Param())
+		}
+		return fmt.Sprintf("must be at least %s%s long", err.Param(), unit)
+	case "max":
+		if unit == "" {
+			return fmt.Sprintf("must be at most %s", err.Param())
+		}
+		return fmt.Sprintf("must be at most %s%s long", err.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s long", err.Param(), unit)
+	case "eq":
+		return fmt.Sprintf("must be equal to %s", err.Param())
+	case "ne":
+		return fmt.Sprintf("must not be equal to %s", err.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", err.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", err.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", err.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", err.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", strings.Join(strings.Fields(err.Param()), ", "))
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "numeric":
+		return "must be a number"
+	case "url":
+		return "must be a valid url"
+	case "uuid", "uuid4":
+		return "must be a valid uuid"
+	case "e164":
+		return "must be a phone number in international format, e.g. +14155552671"
+	case "ip":
+		return "must be a valid ip address"
+	case "hostname":
+		return "must be a valid hostname"
+	case "unique":
+		return "must not contain duplicates"
+	case "startswith":
+		return fmt.Sprintf("must start with %s", err.Param())
+	case "endswith":
+		return fmt.Sprintf("must end with %s", err.Param())
+	case "contains":
+		return fmt.Sprintf("must contain %s", err.Param())
+	case "excludesall":
+		return fmt.Sprintf("must not contain any of %s", err.Param())
 	default:
 		return "is invalid"
 	}
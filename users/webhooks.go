@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+	"users/client"
+	"users/repository"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// delivery statuses, a failed delivery ran out of attempts
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+const (
+	// WebhookSecretPrefix marks signing secrets for secret scanners
+	WebhookSecretPrefix = "whsec"
+
+	// webhookResponseLimit is how much of a receiver's answer is read so the
+	// connection can be reused, the answer itself is not kept
+	webhookResponseLimit = 1024
+)
+
+var ErrWebhookAddress = errors.New("webhook receivers must have a public address")
+
+// reservedPrefixes are not reachable on the internet, on top of the
+// loopback, private, link-local and multicast ranges netip knows about
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddress refuses the addresses a webhook could use to reach the
+// service's own network, like the cloud metadata endpoint
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicHost requires every address the host resolves to to be public
+func publicHost(ctx context.Context, host string) bool {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicDialControl checks the address actually dialed, a host can resolve
+// to another address than when the webhook was validated
+func publicDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+func GenerateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return WebhookSecretPrefix + "_" + hex.EncodeToString(secret), nil
+}
+
+// validateWebhook requires an http(s) url of a public host and known event
+// types, an empty event filter subscribes to every event
+func validateWebhook(ctx context.Context, data *WebhookDTO, allowPrivate bool) ValidationErrors {
+	fields := make(ValidationErrors, 0)
+	if u, err := url.Parse(data.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		fields = append(fields, FieldError{
+			Field:   "url",
+			Rule:    "url",
+			Message: "must be an http or https url",
+		})
+	} else if !allowPrivate && !publicHost(ctx, u.Hostname()) {
+		fields = append(fields, FieldError{
+			Field:   "url",
+			Rule:    "public",
+			Message: "must resolve to public addresses only",
+		})
+	}
+	for i, event := range data.Events {
+		if _, ok := eventType(event); !ok {
+			fields = append(fields, FieldError{
+				Field:   fmt.Sprintf("events[%d]", i),
+				Rule:    "event",
+				Message: "is not a known event type",
+			})
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// webhookEvents stores a filter without events as an empty list, which
+// subscribes to every event
+func webhookEvents(events []string) []string {
+	if events == nil {
+		return make([]string, 0)
+	}
+	return events
+}
+
+func newWebhookSubscriptionDTO(subscription repository.WebhookSubscription) WebhookSubscriptionDTO {
+	return WebhookSubscriptionDTO{
+		ID:          subscription.ID,
+		Url:         subscription.Url,
+		Events:      subscription.Events,
+		Description: subscription.Description,
+		IsActive:    subscription.IsActive,
+		CreatedBy:   subscription.CreatedBy,
+		CreatedAt:   subscription.CreatedAt,
+		UpdatedAt:   subscription.UpdatedAt,
+	}
+}
+
+func newWebhookDeliveryDTO(delivery repository.WebhookDelivery) WebhookDeliveryDTO {
+	return WebhookDeliveryDTO{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        uuid.UUID(delivery.EventID.Bytes).String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+// webhooks handlers
+
+func (h *AuthHandler) ListWebhooks(c echo.Context) error {
+	subscriptions, err := h.Repo.ListWebhookSubscriptions(h.Ctx, tenantID(c))
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	webhooksDTO := make([]WebhookSubscriptionDTO, 0)
+	for _, subscription := range subscriptions {
+		webhooksDTO = append(webhooksDTO, newWebhookSubscriptionDTO(subscription))
+	}
+
+	return NewResponse(c, "success", webhooksDTO, "", http.StatusOK)
+}
+
+func (h *AuthHandler) GetWebhook(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	subscription, err := h.Repo.GetWebhookSubscription(h.Ctx, repository.GetWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	return NewResponse(c, "success", newWebhookSubscriptionDTO(subscription), "", http.StatusOK)
+}
+
+func (h *AuthHandler) CreateWebhook(c echo.Context) error {
+	data := new(WebhookDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+	if fields := validateWebhook(h.Ctx, data, h.Cfg.WebhookAllowPrivate); fields != nil {
+		return ValidationError(fields)
+	}
+
+	secret, err := GenerateWebhookSecret()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	userID, _ := c.Get("userID").(int64)
+	subscription, err := h.Repo.CreateWebhookSubscription(h.Ctx, repository.CreateWebhookSubscriptionParams{
+		OrganizationID: tenantID(c),
+		Url:            data.Url,
+		Events:         webhookEvents(data.Events),
+		Secret:         secret,
+		Description:    pgtype.Text{String: data.Description, Valid: data.Description != ""},
+		IsActive:       data.IsActive == nil || *data.IsActive,
+		CreatedBy:      pgtype.Int4{Int32: int32(userID), Valid: userID != 0},
+	})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	// the secret is only ever returned here and when it is rotated
+	responseData := CreatedWebhookDTO{
+		Secret:  secret,
+		Webhook: newWebhookSubscriptionDTO(subscription),
+	}
+	return NewResponse(c, "success", responseData, "", http.StatusAccepted)
+}
+
+// UpdateWebhook replaces the url, the event filter and the description, an
+// inactive subscription keeps its pending deliveries but gets no new ones
+func (h *AuthHandler) UpdateWebhook(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	data := new(WebhookDTO)
+	err := c.Bind(data)
+	if err != nil {
+		return NewResponse(c, "failed", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if err = c.Validate(data); err != nil {
+		return NewValidationResponse(c, err)
+	}
+	if fields := validateWebhook(h.Ctx, data, h.Cfg.WebhookAllowPrivate); fields != nil {
+		return ValidationError(fields)
+	}
+
+	subscription, err := h.Repo.GetWebhookSubscription(h.Ctx, repository.GetWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	isActive := subscription.IsActive
+	if data.IsActive != nil {
+		isActive = *data.IsActive
+	}
+	subscription, err = h.Repo.UpdateWebhookSubscription(h.Ctx, repository.UpdateWebhookSubscriptionParams{
+		ID:             subscription.ID,
+		Url:            data.Url,
+		Events:         webhookEvents(data.Events),
+		Description:    pgtype.Text{String: data.Description, Valid: data.Description != ""},
+		IsActive:       isActive,
+		OrganizationID: subscription.OrganizationID,
+	})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	return NewResponse(c, "success", newWebhookSubscriptionDTO(subscription), "", http.StatusAccepted)
+}
+
+// RotateWebhookSecret replaces the signing secret, deliveries are signed with
+// the new one from the next attempt on
+func (h *AuthHandler) RotateWebhookSecret(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	secret, err := GenerateWebhookSecret()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	subscription, err := h.Repo.RotateWebhookSecret(h.Ctx, repository.RotateWebhookSecretParams{
+		ID:             int32(id),
+		Secret:         secret,
+		OrganizationID: tenantID(c),
+	})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	responseData := CreatedWebhookDTO{
+		Secret:  secret,
+		Webhook: newWebhookSubscriptionDTO(subscription),
+	}
+	return NewResponse(c, "success", responseData, "", http.StatusAccepted)
+}
+
+// DeleteWebhook deletes the subscription together with its deliveries and
+// their logs
+func (h *AuthHandler) DeleteWebhook(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	deleted, err := h.Repo.DeleteWebhookSubscription(h.Ctx, repository.DeleteWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+	if deleted == 0 {
+		return NotFoundError("webhook not found")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusOK)
+}
+
+// ListWebhookDeliveries lists the latest deliveries of a subscription, limit
+// defaults to 50 and is capped at 500
+func (h *AuthHandler) ListWebhookDeliveries(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	subscription, err := h.Repo.GetWebhookSubscription(h.Ctx, repository.GetWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)})
+	if err != nil {
+		return DBError(err, "webhook")
+	}
+
+	deliveries, err := h.Repo.ListWebhookDeliveries(h.Ctx, repository.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Limit:          int32(min(limit, 500)),
+	})
+	if err != nil {
+		return DBError(err, "webhook delivery")
+	}
+
+	deliveriesDTO := make([]WebhookDeliveryDTO, 0)
+	for _, delivery := range deliveries {
+		deliveriesDTO = append(deliveriesDTO, newWebhookDeliveryDTO(delivery))
+	}
+
+	return NewResponse(c, "success", deliveriesDTO, "", http.StatusOK)
+}
+
+func (h *AuthHandler) GetWebhookDelivery(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	deliveryID, _ := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if _, err := h.Repo.GetWebhookSubscription(h.Ctx, repository.GetWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)}); err != nil {
+		return DBError(err, "webhook")
+	}
+
+	delivery, err := h.Repo.GetWebhookDelivery(h.Ctx, repository.GetWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: int32(id),
+	})
+	if err != nil {
+		return DBError(err, "webhook delivery")
+	}
+
+	attempts, err := h.Repo.ListWebhookDeliveryAttempts(h.Ctx, delivery.ID)
+	if err != nil {
+		return DBError(err, "webhook delivery")
+	}
+
+	logDTO := WebhookDeliveryLogDTO{
+		Delivery: newWebhookDeliveryDTO(delivery),
+		Payload:  delivery.Payload,
+		Attempts: make([]WebhookDeliveryAttemptDTO, 0),
+	}
+	for _, attempt := range attempts {
+		logDTO.Attempts = append(logDTO.Attempts, WebhookDeliveryAttemptDTO{
+			StatusCode:  attempt.StatusCode,
+			Error:       attempt.Error,
+			DurationMs:  attempt.DurationMs,
+			AttemptedAt: attempt.AttemptedAt,
+		})
+	}
+
+	return NewResponse(c, "success", logDTO, "", http.StatusOK)
+}
+
+// RedeliverWebhook queues a delivery again with a fresh set of attempts,
+// whether it succeeded or failed. The receiver gets the same X-Webhook-Id and
+// event id so it can tell a redelivery apart.
+func (h *AuthHandler) RedeliverWebhook(c echo.Context) error {
+	id, _ := strconv.Atoi(c.Param("id"))
+	deliveryID, _ := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if _, err := h.Repo.GetWebhookSubscription(h.Ctx, repository.GetWebhookSubscriptionParams{ID: int32(id), OrganizationID: tenantID(c)}); err != nil {
+		return DBError(err, "webhook")
+	}
+
+	queued, err := h.Repo.RedeliverWebhookDelivery(h.Ctx, repository.RedeliverWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: int32(id),
+	})
+	if err != nil {
+		return DBError(err, "webhook delivery")
+	}
+	if queued == 0 {
+		return NotFoundError("webhook delivery not found")
+	}
+
+	return NewResponse(c, "success", nil, "", http.StatusAccepted)
+}
+
+// WebhookDispatcher posts pending deliveries to their subscriptions. It
+// claims them with SKIP LOCKED and a lease rather than holding locks while
+// posting, a failed delivery is retried with exponential backoff until
+// MaxAttempts ran out.
+type WebhookDispatcher struct {
+	DB          *pgxpool.Pool
+	Repo        *repository.Queries
+	Client      *http.Client
+	Logger      *slog.Logger
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	RetryBase   time.Duration
+	MaxBackoff  time.Duration
+}
+
+// NewWebhookClient does not follow redirects, a receiver that moved has to
+// be updated rather than have its signed deliveries forwarded. Unless
+// allowPrivate is set it only connects to public addresses, without a proxy
+// that would connect for it.
+func NewWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = publicDialControl
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run dispatches on every interval until ctx is done
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	if d.Interval <= 0 || d.BatchSize <= 0 {
+		d.Logger.Info("webhook dispatcher disabled")
+		return
+	}
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means more are waiting, dispatch again right away
+		for d.Dispatch(ctx) == d.BatchSize && ctx.Err() == nil {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lease is how long other dispatchers leave claimed deliveries alone, enough
+// to post a whole batch one after the other. Deliveries of a dispatcher that
+// stopped midway are attempted again once it ran out.
+func (d *WebhookDispatcher) lease() time.Duration {
+	return time.Duration(d.BatchSize+1) * d.Client.Timeout
+}
+
+// Dispatch attempts one batch of due deliveries and returns how many it
+// attempted. The claim commits before posting, each outcome is recorded on
+// its own so a failure never sends the rest of the batch twice.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) int {
+	deliveries, err := d.Repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesParams{
+		Limit:         int32(d.BatchSize),
+		NextAttemptAt: pgtype.Timestamp{Time: time.Now().Add(d.lease()), Valid: true},
+	})
+	if err != nil {
+		d.Logger.Error("failed to claim webhook deliveries: ", "error", err)
+		return 0
+	}
+
+	subscriptions := make(map[int32]repository.WebhookSubscription)
+	for _, delivery := range deliveries {
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = d.Repo.GetDeliverySubscription(ctx, delivery.SubscriptionID)
+			if err != nil {
+				d.Logger.Error("failed to load webhook: ", "id", delivery.SubscriptionID, "error", err)
+				continue
+			}
+			subscriptions[subscription.ID] = subscription
+		}
+
+		if err = d.attempt(ctx, subscription, delivery); err != nil {
+			d.Logger.Error("failed to log webhook delivery: ", "id", delivery.ID, "error", err)
+		}
+	}
+	return len(deliveries)
+}
+
+// attempt posts a delivery once and logs the outcome, only a 2xx answer
+// counts as delivered
+func (d *WebhookDispatcher) attempt(ctx context.Context, subscription repository.WebhookSubscription, delivery repository.WebhookDelivery) error {
+	start := time.Now()
+	statusCode, err := d.post(ctx, subscription, delivery)
+	duration := time.Since(start)
+
+	attempt := repository.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		StatusCode: pgtype.Int4{Int32: int32(statusCode), Valid: statusCode != 0},
+		DurationMs: int32(duration.Milliseconds()),
+	}
+	update := repository.UpdateWebhookDeliveryParams{
+		ID:             delivery.ID,
+		Status:         WebhookDeliverySucceeded,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: attempt.StatusCode,
+	}
+
+	switch {
+	case err != nil:
+		attempt.Error = pgtype.Text{String: err.Error(), Valid: true}
+	case statusCode < 200 || statusCode > 299:
+		attempt.Error = pgtype.Text{String: fmt.Sprintf("receiver answered %d", statusCode), Valid: true}
+	}
+
+	if attempt.Error.Valid {
+		update.LastError = attempt.Error
+		update.Status = WebhookDeliveryPending
+		update.NextAttemptAt = pgtype.Timestamp{Time: time.Now().Add(retryBackoff(d.RetryBase, d.MaxBackoff, delivery.Attempts)), Valid: true}
+		if int(delivery.Attempts)+1 >= d.MaxAttempts {
+			update.Status = WebhookDeliveryFailed
+		}
+		d.Logger.Warn("webhook delivery failed: ", "id", delivery.ID, "webhook", subscription.ID, "attempts", delivery.Attempts+1, "error", attempt.Error.String)
+	} else {
+		update.DeliveredAt = pgtype.Timestamp{Time: time.Now(), Valid: true}
+	}
+
+	tx, err := d.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := d.Repo.WithTx(tx)
+	if err = qtx.CreateWebhookDeliveryAttempt(ctx, attempt); err != nil {
+		return err
+	}
+	if err = qtx.UpdateWebhookDelivery(ctx, update); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// post signs the delivery with the current secret, so a redelivery after a
+// rotation carries a signature the receiver can check. Only the status of
+// the answer is kept, a receiver's body can hold anything.
+func (d *WebhookDispatcher) post(ctx context.Context, subscription repository.WebhookSubscription, delivery repository.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "users-webhooks/1")
+	req.Header.Set(client.WebhookIDHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(client.WebhookEventHeader, delivery.EventType)
+	req.Header.Set(client.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(client.WebhookSignatureHeader, client.SignWebhook(subscription.Secret, timestamp, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, webhookResponseLimit))
+	return res.StatusCode, nil
+}
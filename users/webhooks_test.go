@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"users/client"
+	"users/repository"
+)
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	secret, err := GenerateWebhookSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verifyErr error
+	var header http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		header = r.Header
+		verifyErr = client.VerifyWebhook(secret, r.Header, body, client.DefaultWebhookTolerance)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	dispatcher := &WebhookDispatcher{Client: NewWebhookClient(time.Second, true)}
+	subscription := repository.WebhookSubscription{ID: 1, Url: receiver.URL, Secret: secret}
+	delivery := repository.WebhookDelivery{ID: 7, EventType: EventUserVerified, Payload: []byte(`{"type":"user.verified"}`)}
+
+	status, err := dispatcher.post(context.Background(), subscription, delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusNoContent {
+		t.Fatalf("status = %d", status)
+	}
+	if verifyErr != nil {
+		t.Fatalf("receiver could not verify the delivery: %v", verifyErr)
+	}
+	if header.Get(client.WebhookIDHeader) != "7" || header.Get(client.WebhookEventHeader) != EventUserVerified {
+		t.Fatalf("headers = %v", header)
+	}
+
+	// a tampered body or another secret fails
+	if err = client.VerifyWebhook(secret, header, []byte(`{"type":"user.deleted"}`), client.DefaultWebhookTolerance); !errors.Is(err, client.ErrWebhookSignature) {
+		t.Fatalf("tampered body: err = %v", err)
+	}
+	if err = client.VerifyWebhook("whsec_other", header, delivery.Payload, client.DefaultWebhookTolerance); !errors.Is(err, client.ErrWebhookSignature) {
+		t.Fatalf("other secret: err = %v", err)
+	}
+}
+
+func TestVerifyWebhookRejectsOldDeliveries(t *testing.T) {
+	body := []byte(`{}`)
+	timestamp := time.Now().Add(-time.Hour).Unix()
+	header := http.Header{}
+	header.Set(client.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(client.WebhookSignatureHeader, client.SignWebhook("secret", timestamp, body))
+
+	if err := client.VerifyWebhook("secret", header, body, client.DefaultWebhookTolerance); !errors.Is(err, client.ErrWebhookTimestamp) {
+		t.Fatalf("err = %v", err)
+	}
+	if err := client.VerifyWebhook("secret", header, body, 2*time.Hour); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWebhookRedirectIsNotFollowed(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the redirect was followed")
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer receiver.Close()
+
+	dispatcher := &WebhookDispatcher{Client: NewWebhookClient(time.Second, true)}
+	subscription := repository.WebhookSubscription{Url: receiver.URL, Secret: "secret"}
+	status, err := dispatcher.post(context.Background(), subscription, repository.WebhookDelivery{Payload: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusTemporaryRedirect {
+		t.Fatalf("status = %d", status)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	ctx := context.Background()
+	fields := validateWebhook(ctx, &WebhookDTO{Url: "ftp://example.com", Events: []string{EventUserDeleted, "user.unknown"}}, false)
+	if len(fields) != 2 || fields[0].Field != "url" || fields[1].Field != "events[1]" {
+		t.Fatalf("fields = %+v", fields)
+	}
+	if fields = validateWebhook(ctx, &WebhookDTO{Url: "https://93.184.215.14/hook"}, false); fields != nil {
+		t.Fatalf("fields = %+v", fields)
+	}
+
+	for _, u := range []string{"http://127.0.0.1/hook", "http://localhost:8080", "http://169.254.169.254/latest/meta-data", "http://10.0.0.5", "http://[::1]/hook", "http://[fd00::1]", "http://100.64.0.1", "http://[::ffff:192.168.1.1]"} {
+		if fields = validateWebhook(ctx, &WebhookDTO{Url: u}, false); len(fields) != 1 || fields[0].Rule != "public" {
+			t.Errorf("%s: fields = %+v", u, fields)
+		}
+		if fields = validateWebhook(ctx, &WebhookDTO{Url: u}, true); fields != nil {
+			t.Errorf("%s allowing private addresses: fields = %+v", u, fields)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a loopback receiver was reached")
+	}))
+	defer receiver.Close()
+
+	dispatcher := &WebhookDispatcher{Client: NewWebhookClient(time.Second, false)}
+	subscription := repository.WebhookSubscription{Url: receiver.URL, Secret: "secret"}
+	if _, err := dispatcher.post(context.Background(), subscription, repository.WebhookDelivery{Payload: []byte(`{}`)}); !errors.Is(err, ErrWebhookAddress) {
+		t.Fatalf("err = %v", err)
+	}
+}